@@ -0,0 +1,376 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zebbra/vmanage-exporter/internal/lib/collector"
+	"github.com/zebbra/vmanage-exporter/internal/lib/config"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+	"go.uber.org/zap"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// exporter holds the vManage client and the collector of a target built from the current configuration.
+// On reload they are replaced, while the cache and therefore the collected data is kept.
+type exporter struct {
+	Name         string
+	Logger       *zap.SugaredLogger
+	Cache        *cache.Cache
+	ErrorCounter *collector.Counter
+	// Status records the outcome of collector runs and API requests across reloads.
+	Status *collector.Status
+	// Limiter limits the number of devices refreshed concurrently by all collectors of the target
+	// to the number of workers and adapts it if enabled. It is kept across reloads.
+	Limiter *collector.Limiter
+	// Breaker is shared by the clients of the target, so its state is kept across reloads.
+	Breaker *vmanage.Breaker
+	// Registry exposes the metrics of the target, labelled with its name.
+	Registry *prometheus.Registry
+
+	mu         sync.RWMutex
+	cfg        *config.Target
+	client     *vmanage.Client
+	collectors *collectorSet
+	done       chan struct{}
+	stopped    bool
+
+	// tenants are the selected tenants of a multi-tenant vManage, each with a cache of its own.
+	tenants      []vmanage.Tenant
+	tenantCaches map[string]*cache.Cache
+
+	// ctx is cancelled on Stop to abort running refreshes, which are tracked by running.
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+
+	// clientCtx is cancelled when client is replaced on reload, clientRuns tracks the refreshes using client.
+	clientCtx    context.Context
+	clientCancel context.CancelFunc
+	clientRuns   *sync.WaitGroup
+
+	// inflight holds the names of the refreshes currently running, see tryRun.
+	inflightMu sync.Mutex
+	inflight   map[string]bool
+}
+
+func newExporter(ctx context.Context, cfg *config.Target, logger *zap.SugaredLogger, errorCounter *collector.Counter) (*exporter, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	e := &exporter{
+		ctx:          ctx,
+		cancel:       cancel,
+		inflight:     map[string]bool{},
+		tenantCaches: map[string]*cache.Cache{},
+		Name:         cfg.Name,
+		Logger:       logger.With("vmanage", cfg.Name),
+		Cache:        cache.New(5*cfg.Scrape.Interval, 10*cfg.Scrape.Interval),
+		ErrorCounter: errorCounter,
+		Status:       collector.NewStatus(),
+		Limiter:      collector.NewLimiter(cfg.Scrape.Workers, cfg.Scrape.SlowRequest, cfg.Scrape.AdaptiveWorkers),
+		Breaker:      vmanage.NewBreaker(cfg.VManage.BreakerThreshold, cfg.VManage.BreakerTimeout),
+		Registry:     prometheus.NewRegistry(),
+	}
+
+	if err := e.apply(cfg); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	prometheus.WrapRegistererWith(prometheus.Labels{"vmanage": cfg.Name}, e.Registry).MustRegister(e)
+
+	return e, nil
+}
+
+func (e *exporter) config() *config.Target {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.cfg
+}
+
+// newAuthenticator returns the authenticator of the configured login method.
+func newAuthenticator(cfg config.VManage) vmanage.Authenticator {
+	switch cfg.Auth {
+	case "session":
+		return &vmanage.SessionAuth{}
+	case "jwt":
+		return &vmanage.JWTAuth{Duration: cfg.JWTDuration}
+	default:
+		return &vmanage.AutoAuth{JWT: vmanage.JWTAuth{Duration: cfg.JWTDuration}}
+	}
+}
+
+// apply builds client and collectors from cfg. The client is only replaced if its settings changed.
+func (e *exporter) apply(cfg *config.Target) error {
+	e.mu.RLock()
+	old := e.cfg
+	client := e.client
+	e.mu.RUnlock()
+
+	var username, password string
+
+	if cfg.VManage.ReplayDir == "" {
+		var err error
+		username, password, err = cfg.VManage.Credentials.Resolve()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if client == nil ||
+		!reflect.DeepEqual(old.VManage, cfg.VManage) ||
+		client.Username != username ||
+		client.Password != password {
+		client = vmanage.NewClient(cfg.VManage.Endpoint, username, password)
+
+		if !cfg.VManage.TLSVerify {
+			client.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		}
+
+		client.Auth = newAuthenticator(cfg.VManage)
+		client.Endpoints = cfg.VManage.Endpoints
+		client.HealthInterval = cfg.VManage.HealthInterval
+		client.Timeout = cfg.VManage.Timeout
+		client.PoolSize = cfg.VManage.PoolSize
+		client.IdleConnTimeout = cfg.VManage.IdleTimeout
+		client.PageSize = cfg.VManage.PageSize
+		client.RateLimit = cfg.VManage.RateLimit
+		client.RateBurst = cfg.VManage.RateBurst
+		client.Retries = cfg.VManage.Retries
+		client.RetryWait = cfg.VManage.RetryWait
+		client.RetryMaxWait = cfg.VManage.RetryMaxWait
+		client.Breaker = e.Breaker
+		client.OnRetry = e.Status.ObserveRetry
+		client.OnRequest = e.Status.ObserveRequest
+		client.OnFailover = e.failover
+
+		if cfg.VManage.RecordDir != "" {
+			client.RecordDir = filepath.Join(cfg.VManage.RecordDir, cfg.Name)
+			e.Logger.Warnf("Recording vManage responses to %s", client.RecordDir)
+		}
+
+		if cfg.VManage.ReplayDir != "" {
+			client.ReplayDir = filepath.Join(cfg.VManage.ReplayDir, cfg.Name)
+			e.Logger.Warnf("Replaying vManage responses from %s", client.ReplayDir)
+		}
+
+		e.Logger.Infof("Validate login on %s", cfg.VManage.Endpoint)
+
+		if err := client.Login(e.ctx); err != nil {
+			return fmt.Errorf("Login to %s failed: %w", cfg.VManage.Endpoint, err)
+		}
+
+		if auto, ok := client.Auth.(*vmanage.AutoAuth); ok {
+			method := "session"
+
+			if _, ok := auto.Selected().(*vmanage.JWTAuth); ok {
+				method = "jwt"
+			}
+
+			e.Logger.Infow("Selected login method", "method", method)
+		}
+	}
+
+	e.Status.SetWindow(cfg.Scrape.ErrorWindow)
+	e.Limiter.Configure(cfg.Scrape.Workers, cfg.Scrape.SlowRequest, cfg.Scrape.AdaptiveWorkers)
+	e.Breaker.Configure(cfg.VManage.BreakerThreshold, cfg.VManage.BreakerTimeout)
+
+	e.mu.Lock()
+	oldClient := e.client
+	oldCancel, oldRuns := e.clientCancel, e.clientRuns
+	e.cfg = cfg
+
+	if client != oldClient {
+		e.client = client
+		e.clientCtx, e.clientCancel = context.WithCancel(e.ctx)
+		e.clientRuns = &sync.WaitGroup{}
+	}
+
+	e.collectors = e.newCollectorSet(cfg, client, e.tenants, e.tenantCache(cfg), e.Status)
+
+	if e.done != nil && !reflect.DeepEqual(intervals(old), intervals(cfg)) {
+		close(e.done)
+		e.schedule(cfg)
+	}
+	e.mu.Unlock()
+
+	if oldClient != nil && oldClient != client {
+		// refreshes still running with the old client would log in again
+		oldCancel()
+		oldRuns.Wait()
+
+		if err := oldClient.Logout(); err != nil {
+			e.Logger.Warnw("Error logging out of replaced client", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// acquire returns the context of the current client for a refresh, which is cancelled when the client
+// is replaced on reload, and a function to call once the refresh ended.
+func (e *exporter) acquire() (context.Context, func()) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	e.clientRuns.Add(1)
+	return e.clientCtx, e.clientRuns.Done
+}
+
+// failover logs that requests to vManage switched to another node of the cluster.
+func (e *exporter) failover(from string, to string, cause error) {
+	if cause == nil {
+		e.Logger.Infow("Returned to preferred vManage node", "from", from, "to", to)
+	} else {
+		e.Logger.Warnw("Failed over to another vManage node", "from", from, "to", to, "error", cause)
+	}
+
+	e.Status.ObserveFailover(to)
+}
+
+func (e *exporter) newCollector(cfg *config.Target, client *vmanage.Client, c *cache.Cache, status *collector.Status) *collector.VmanageCollector {
+	filter := &collector.DeviceFilter{
+		SiteIDs:     cfg.Filters.SiteIDs,
+		DeviceTypes: cfg.Filters.DeviceTypes,
+	}
+
+	// expressions have been validated with the configuration
+	if cfg.Filters.Hostname != "" {
+		filter.Hostname = regexp.MustCompile(cfg.Filters.Hostname)
+	}
+
+	if cfg.Filters.ExcludeHostname != "" {
+		filter.ExcludeHostname = regexp.MustCompile(cfg.Filters.ExcludeHostname)
+	}
+
+	return &collector.VmanageCollector{
+		Logger:        e.Logger,
+		Client:        client,
+		Cache:         c,
+		ErrorCounter:  e.ErrorCounter,
+		Status:        status,
+		Collectors:    cfg.Collectors.Enabled,
+		Bulk:          cfg.Scrape.Bulk,
+		Workers:       cfg.Scrape.Workers,
+		Limiter:       e.Limiter,
+		InfoLabels:    cfg.Labels.DeviceInfo,
+		Filter:        filter,
+		Intervals:     intervals(cfg),
+		AlarmLookback: cfg.Collectors.AlarmsLookback,
+	}
+}
+
+// intervals returns the refresh intervals of the enabled sub-collectors.
+func intervals(cfg *config.Target) map[string]time.Duration {
+	res := map[string]time.Duration{}
+
+	for _, name := range cfg.Collectors.Enabled {
+		res[name] = cfg.Interval(name)
+	}
+
+	return res
+}
+
+func (e *exporter) current() *collectorSet {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.collectors
+}
+
+// Run refreshes the device list and the data of all sub-collectors once.
+func (e *exporter) Run() {
+	if !e.begin() {
+		return
+	}
+	defer e.running.Done()
+
+	ctx, done := e.acquire()
+	defer done()
+
+	_ = e.refreshTenants(ctx)
+	_ = e.current().Run(ctx)
+}
+
+// begin registers a refresh unless the exporter is stopped. Stop waits for registered refreshes to end.
+func (e *exporter) begin() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.stopped {
+		return false
+	}
+
+	e.running.Add(1)
+	return true
+}
+
+// Start refreshes the device list and every sub-collector periodically.
+func (e *exporter) Start() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.stopped {
+		return
+	}
+
+	e.schedule(e.cfg)
+}
+
+// Stop ends the periodic refresh, cancels running refreshes, waits for them to return
+// and logs out of vManage.
+func (e *exporter) Stop() {
+	e.mu.Lock()
+	e.stopped = true
+
+	if e.done != nil {
+		close(e.done)
+		e.done = nil
+	}
+
+	client := e.client
+	e.mu.Unlock()
+
+	e.cancel()
+	e.running.Wait()
+
+	if err := client.Logout(); err != nil {
+		e.Logger.Warnw("Error logging out", "error", err)
+	} else {
+		e.Logger.Infow("Logged out of vManage")
+	}
+}
+
+// Describe sends no descriptors, which registers the exporter as unchecked collector:
+// the metrics it exposes change with the configuration and the collected data.
+func (e *exporter) Describe(ch chan<- *prometheus.Desc) {
+}
+
+func (e *exporter) Collect(ch chan<- prometheus.Metric) {
+	e.current().Collect(ch)
+	e.Status.Collect(ch)
+
+	if e.config().Scrape.AdaptiveWorkers {
+		e.Limiter.Collect(ch)
+	}
+
+	if len(e.config().VManage.Endpoints) > 0 {
+		e.mu.RLock()
+		client := e.client
+		e.mu.RUnlock()
+
+		(&collector.NodeCollector{Client: client}).Collect(ch)
+	}
+
+	if e.config().VManage.BreakerThreshold > 0 {
+		(&collector.BreakerCollector{Breaker: e.Breaker}).Collect(ch)
+	}
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// targetHealth describes why a target is ready or not.
+type targetHealth struct {
+	Ready       bool       `json:"ready"`
+	LastRefresh *time.Time `json:"last_refresh,omitempty"`
+	Errors      int        `json:"errors"`
+	Requests    int        `json:"requests"`
+	Reasons     []string   `json:"reasons,omitempty"`
+}
+
+// health checks that the device list, and the tenant list in multi-tenant mode, were refreshed recently
+// and that the fraction of failed requests within the error window does not exceed the threshold.
+func (e *exporter) health() targetHealth {
+	cfg := e.config()
+	h := targetHealth{}
+
+	if !cfg.Scrape.ProbeOnly {
+		maxAge := time.Duration(cfg.Scrape.StaleIntervals) * cfg.Scrape.Interval
+
+		if last := e.Status.LastSuccess("device_list"); last.IsZero() {
+			h.Reasons = append(h.Reasons, "device list has not been refreshed yet")
+		} else {
+			h.LastRefresh = &last
+
+			if age := time.Since(last); age > maxAge {
+				h.Reasons = append(h.Reasons, fmt.Sprintf("device list was last refreshed %s ago, more than %s", age.Round(time.Second), maxAge))
+			}
+		}
+
+		// the device lists of the previous tenants are still refreshed if the tenant list fails
+		if len(cfg.VManage.Tenants) > 0 {
+			if last := e.Status.LastSuccess("tenant_list"); last.IsZero() {
+				h.Reasons = append(h.Reasons, "tenant list has not been refreshed yet")
+			} else if age := time.Since(last); age > maxAge {
+				h.Reasons = append(h.Reasons, fmt.Sprintf("tenant list was last refreshed %s ago, more than %s", age.Round(time.Second), maxAge))
+			}
+		}
+	}
+
+	h.Errors, h.Requests = e.Status.Errors()
+
+	if h.Requests > 0 && float64(h.Errors)/float64(h.Requests) > cfg.Scrape.MaxErrorRatio {
+		h.Reasons = append(h.Reasons, fmt.Sprintf("%d of %d requests failed within %s, more than %g%%", h.Errors, h.Requests, cfg.Scrape.ErrorWindow, cfg.Scrape.MaxErrorRatio*100))
+	}
+
+	// deprecated absolute limit, only checked if configured
+	if cfg.Scrape.MaxErrors > 0 && h.Errors > cfg.Scrape.MaxErrors {
+		h.Reasons = append(h.Reasons, fmt.Sprintf("%d requests failed within %s, more than %d", h.Errors, cfg.Scrape.ErrorWindow, cfg.Scrape.MaxErrors))
+	}
+
+	h.Ready = len(h.Reasons) == 0
+	return h
+}
+
+// ready responds with 200 if all targets are ready and 503 otherwise.
+func (t *targets) ready(w http.ResponseWriter, r *http.Request) {
+	res := struct {
+		Status  string                  `json:"status"`
+		Targets map[string]targetHealth `json:"targets"`
+	}{
+		Status:  "ready",
+		Targets: map[string]targetHealth{},
+	}
+
+	t.mu.RLock()
+	for name, e := range t.exporters {
+		h := e.health()
+		res.Targets[name] = h
+
+		if !h.Ready {
+			res.Status = "not ready"
+		}
+	}
+	t.mu.RUnlock()
+
+	code := http.StatusOK
+
+	if res.Status != "ready" {
+		code = http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, code, res)
+}
+
+// healthy responds with 200 as long as the process is able to serve requests.
+func healthy(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "healthy"})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
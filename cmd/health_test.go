@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthErrorRatio(t *testing.T) {
+	e, _ := newTestExporter(t)
+	e.Run()
+
+	observe := func(n int, err error) {
+		for i := 0; i < n; i++ {
+			e.Status.ObserveRequest("GET", "/dataservice/device", 0, time.Millisecond, err)
+		}
+	}
+
+	observe(100, nil)
+	observe(10, errors.New("failed"))
+
+	if h := e.health(); !h.Ready {
+		t.Errorf("Expected target with few failed requests to be ready, got %v", h.Reasons)
+	}
+
+	observe(40, errors.New("failed"))
+
+	if h := e.health(); h.Ready {
+		t.Errorf("Expected target with %d of %d failed requests not to be ready", h.Errors, h.Requests)
+	}
+}
+
+func TestHealthMaxErrors(t *testing.T) {
+	e, _ := newTestExporter(t)
+	e.Run()
+	e.cfg.Scrape.MaxErrors = 5
+
+	for i := 0; i < 100; i++ {
+		e.Status.ObserveRequest("GET", "/dataservice/device", 0, time.Millisecond, nil)
+	}
+
+	for i := 0; i < 6; i++ {
+		e.Status.ObserveRequest("GET", "/dataservice/device", 0, time.Millisecond, errors.New("failed"))
+	}
+
+	if h := e.health(); h.Ready || !containsReason(h, "more than 5") {
+		t.Errorf("Expected target with more failed requests than max_errors not to be ready, got %v", h.Reasons)
+	}
+}
+
+func TestHealthTenants(t *testing.T) {
+	e, srv := newTestExporter(t, "*")
+	srv.Fail("/dataservice/tenant", http.StatusBadRequest)
+	e.Run()
+
+	if h := e.health(); h.Ready || !containsReason(h, "tenant list") {
+		t.Errorf("Expected target not to be ready as the tenant list failed, got %v", h.Reasons)
+	}
+
+	e.Run()
+
+	if h := e.health(); !h.Ready {
+		t.Errorf("Expected target to be ready after the tenant list was refreshed, got %v", h.Reasons)
+	}
+
+	e, _ = newTestExporter(t, "missing")
+	e.Run()
+
+	if h := e.health(); h.Ready || !containsReason(h, "device list") {
+		t.Errorf("Expected target without selected tenants not to be ready as the device list failed, got %v", h.Reasons)
+	}
+}
+
+func containsReason(h targetHealth, s string) bool {
+	for _, r := range h.Reasons {
+		if strings.Contains(r, s) {
+			return true
+		}
+	}
+
+	return false
+}
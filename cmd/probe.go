@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/zebbra/vmanage-exporter/internal/lib/collector"
+	"github.com/zebbra/vmanage-exporter/internal/lib/config"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// probe collects the target named in the request on demand and responds with its metrics only.
+// The timeout is taken from the scrape timeout sent by Prometheus.
+func (t *targets) probe(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("target")
+
+	t.mu.RLock()
+	e, ok := t.exporters[name]
+	t.mu.RUnlock()
+
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown target %q", name), http.StatusBadRequest)
+		return
+	}
+
+	cfg := *e.config()
+	timeout := probeTimeout(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), cfg.Scrape.Interval)
+
+	// modules select the sub-collectors to run, the device list is always collected
+	if modules := r.URL.Query().Get("module"); modules != "" {
+		cfg.Collectors.Enabled = strings.Split(modules, ",")
+
+		for _, m := range cfg.Collectors.Enabled {
+			if !contains(collector.Names(), m) {
+				http.Error(
+					w,
+					fmt.Sprintf("Unknown module %q, valid modules are %s", m, strings.Join(collector.Names(), ", ")),
+					http.StatusBadRequest,
+				)
+				return
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	reg := prometheus.NewRegistry()
+	prometheus.WrapRegistererWith(prometheus.Labels{"vmanage": name}, reg).MustRegister(e.probe(ctx, &cfg))
+
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// probeCollector exposes the data of collectors run for a single probe.
+type probeCollector struct {
+	collector *collectorSet
+	duration  time.Duration
+	success   bool
+}
+
+// probe runs the sub-collectors enabled in cfg, for every tenant in multi-tenant mode, with caches of their own
+// and a client which does not record their requests, so the data and the readiness of the periodic refresh
+// are not affected. The tenants are fetched for the probe, the ones of the periodic refresh are kept.
+func (e *exporter) probe(ctx context.Context, cfg *config.Target) *probeCollector {
+	startTime := time.Now()
+
+	// a reload waits for the probe before logging out of the client it uses
+	_, done := e.acquire()
+	defer done()
+
+	e.mu.RLock()
+	client := e.client.WithObserver(nil)
+	tenants := e.tenants
+	e.mu.RUnlock()
+
+	var tenantErr error
+
+	if len(cfg.VManage.Tenants) > 0 {
+		var all []vmanage.Tenant
+
+		if all, tenantErr = client.Tenant(ctx); tenantErr == nil {
+			tenants, _ = selectTenants(all, cfg.VManage.Tenants)
+		}
+	}
+
+	p := &probeCollector{
+		collector: e.newCollectorSet(cfg, client, tenants, func(string) *cache.Cache {
+			return cache.New(cache.NoExpiration, 0)
+		}, nil),
+	}
+
+	p.success = tenantErr == nil && p.collector.Run(ctx) == nil && ctx.Err() == nil
+
+	p.duration = time.Since(startTime)
+	return p
+}
+
+// probeTimeout returns the scrape timeout sent by Prometheus less the time needed to serialize the response:
+// 500ms, but at most a tenth of the timeout. Without a valid timeout, the scrape interval is used.
+func probeTimeout(header string, interval time.Duration) time.Duration {
+	s, err := strconv.ParseFloat(header, 64)
+
+	if err != nil || s <= 0 {
+		return interval
+	}
+
+	timeout := time.Duration(s * float64(time.Second))
+	margin := 500 * time.Millisecond
+
+	if timeout/10 < margin {
+		margin = timeout / 10
+	}
+
+	return timeout - margin
+}
+
+// Describe sends no descriptors, see exporter.Describe.
+func (p *probeCollector) Describe(ch chan<- *prometheus.Desc) {
+}
+
+func (p *probeCollector) Collect(ch chan<- prometheus.Metric) {
+	success := 0.0
+
+	if p.success {
+		success = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc(
+			"probe_success",
+			"Whether the probe succeeded",
+			[]string{},
+			nil,
+		),
+		prometheus.GaugeValue,
+		success,
+	)
+
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc(
+			"probe_duration_seconds",
+			"Duration of the probe",
+			[]string{},
+			nil,
+		),
+		prometheus.GaugeValue,
+		p.duration.Seconds(),
+	)
+
+	p.collector.Collect(ch)
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"context"
+	"github.com/zebbra/vmanage-exporter/internal/lib/collector"
+	"github.com/zebbra/vmanage-exporter/internal/lib/config"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage/vmanagetest"
+	"go.uber.org/zap"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// newTestExporter returns an exporter of a target on a fake vManage, in multi-tenant mode if tenants are given.
+func newTestExporter(t *testing.T, tenants ...string) (*exporter, *vmanagetest.Server) {
+	t.Helper()
+
+	srv := vmanagetest.NewServer()
+	t.Cleanup(srv.Close)
+
+	cfg := testTarget(t, "test", srv)
+	cfg.VManage.Tenants = tenants
+
+	errorCounter := collector.Counter(0)
+	e, err := newExporter(context.Background(), cfg, zap.NewNop().Sugar(), &errorCounter)
+
+	if err != nil {
+		t.Fatalf("Error creating exporter: %s", err)
+	}
+
+	t.Cleanup(e.Stop)
+	return e, srv
+}
+
+// testTarget returns the configuration of a target named name on srv with session login.
+func testTarget(t *testing.T, name string, srv *vmanagetest.Server) *config.Target {
+	t.Helper()
+
+	t.Setenv("TEST_VMANAGE_USER", vmanagetest.Username)
+	t.Setenv("TEST_VMANAGE_PASSWORD", vmanagetest.Password)
+
+	return &config.Target{
+		Name: name,
+		VManage: config.VManage{
+			Endpoint:     srv.URL,
+			Credentials:  config.Credentials{UsernameEnv: "TEST_VMANAGE_USER", PasswordEnv: "TEST_VMANAGE_PASSWORD"},
+			Auth:         "session",
+			Timeout:      time.Second,
+			PoolSize:     2,
+			PageSize:     1000,
+			RetryWait:    time.Millisecond,
+			RetryMaxWait: time.Millisecond,
+		},
+		Scrape: config.Scrape{
+			Interval:       time.Minute,
+			Workers:        2,
+			MaxErrorRatio:  0.2,
+			ErrorWindow:    time.Minute,
+			StaleIntervals: 3,
+		},
+		Collectors: config.Collectors{Enabled: []string{"devices"}},
+	}
+}
+
+func TestProbeKeepsReadiness(t *testing.T) {
+	e, srv := newTestExporter(t)
+
+	if p := e.probe(context.Background(), e.config()); !p.success {
+		t.Fatal("Expected probe to succeed")
+	}
+
+	if h := e.health(); h.Ready {
+		t.Error("Expected target not to be ready before the periodic refresh, a probe must not mark it refreshed")
+	}
+
+	e.Run()
+	last := e.Status.LastSuccess("device_list")
+
+	if h := e.health(); !h.Ready {
+		t.Fatalf("Expected target to be ready after the periodic refresh, got %v", h.Reasons)
+	}
+
+	failed, requests := e.Status.Errors()
+
+	for i := 0; i < 5; i++ {
+		srv.Fail("/dataservice/device", http.StatusBadRequest)
+
+		if p := e.probe(context.Background(), e.config()); p.success {
+			t.Fatal("Expected probe to fail")
+		}
+	}
+
+	if l := e.Status.LastSuccess("device_list"); !l.Equal(last) {
+		t.Errorf("Expected last refresh %s to be kept, got %s", last, l)
+	}
+
+	if h := e.health(); !h.Ready {
+		t.Errorf("Expected target to stay ready after failed probes, got %v", h.Reasons)
+	}
+
+	if n, m := e.Status.Errors(); n != failed || m != requests {
+		t.Errorf("Expected %d of %d failed requests to be kept, got %d of %d", failed, requests, n, m)
+	}
+}
+
+func TestProbeTenants(t *testing.T) {
+	e, srv := newTestExporter(t, "*")
+	e.Run()
+
+	last := e.Status.LastSuccess("tenant_list")
+	collectors := e.current()
+
+	srv.Fail("/dataservice/tenant", http.StatusBadRequest)
+
+	if p := e.probe(context.Background(), e.config()); p.success {
+		t.Fatal("Expected probe to fail with the tenant list")
+	}
+
+	if p := e.probe(context.Background(), e.config()); !p.success || len(p.collector.collectors) != 2 {
+		t.Fatal("Expected probe to collect both tenants")
+	}
+
+	if l := e.Status.LastSuccess("tenant_list"); !l.Equal(last) {
+		t.Errorf("Expected last tenant list refresh %s to be kept, got %s", last, l)
+	}
+
+	if e.current() != collectors {
+		t.Error("Expected probes to keep the collectors of the periodic refresh")
+	}
+
+	if h := e.health(); !h.Ready {
+		t.Errorf("Expected target to stay ready after failed probes, got %v", h.Reasons)
+	}
+}
+
+func TestProbeTimeout(t *testing.T) {
+	for header, expected := range map[string]time.Duration{
+		"10":    9500 * time.Millisecond,
+		"0.5":   450 * time.Millisecond,
+		"0.2":   180 * time.Millisecond,
+		"0":     time.Minute,
+		"-1":    time.Minute,
+		"":      time.Minute,
+		"never": time.Minute,
+	} {
+		if timeout := probeTimeout(header, time.Minute); timeout != expected {
+			t.Errorf("Expected timeout %s for %q, got %s", expected, header, timeout)
+		}
+	}
+}
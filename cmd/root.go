@@ -2,19 +2,19 @@ package cmd
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
-	"github.com/patrickmn/go-cache"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 	"github.com/zebbra/vmanage-exporter/internal/lib/collector"
+	"github.com/zebbra/vmanage-exporter/internal/lib/config"
 	"github.com/zebbra/vmanage-exporter/internal/lib/version"
-	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
 	"go.uber.org/zap"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -25,24 +25,7 @@ var rootCmd = &cobra.Command{
 	Use:           "vmanage-exporter --vmanage.endpoint <url>",
 	SilenceErrors: true,
 	Version:       fmt.Sprintf("%s-%s", version.Version, version.Commit),
-	PreRunE: func(cmd *cobra.Command, args []string) error {
-		if os.Getenv(userEnv) == "" || os.Getenv(passwordEnv) == "" {
-			return fmt.Errorf(
-				"Please provide vmanage credentials in environment variables %s and %s",
-				userEnv,
-				passwordEnv,
-			)
-		}
-
-		return nil
-	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		endpoint, err := cmd.Flags().GetString("vmanage.endpoint")
-
-		if err != nil {
-			return err
-		}
-
 		addr, err := cmd.Flags().GetString("web.listen-address")
 
 		if err != nil {
@@ -55,7 +38,7 @@ var rootCmd = &cobra.Command{
 			return err
 		}
 
-		scrapeInterval, err := cmd.Flags().GetDuration("scrape.interval")
+		cfg, err := loadConfig(cmd)
 
 		if err != nil {
 			return err
@@ -65,102 +48,92 @@ var rootCmd = &cobra.Command{
 		defer logger.Sync()
 		sugar := logger.Sugar()
 
-		sugar.Infof("Validate login on %s", endpoint)
-
-		vmClient := vmanage.NewClient(
-			endpoint,
-			os.Getenv(userEnv),
-			os.Getenv(passwordEnv),
-		)
-
-		if v, _ := cmd.Flags().GetBool("tls.verify"); !v {
-			vmClient.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-		}
-
-		err = vmClient.Login()
-
-		if err != nil {
-			return fmt.Errorf("Initial login to %s failed: %w", endpoint, err)
-		}
-
-		//o := &vmanage.DeviceInterfaceListOptions{DeviceID: "10.10.1.5"}
-
-		//devices, err := vmClient.Device(context.Background())
-		//
-		//if err != nil {
-		//	return err
-		//}
-		//
-		//litter.Dump(devices)
-		//_ = devices
-		//
-		//err = vmClient.Logout()
-		//if err != nil {
-		//	return err
-		//}
-		//
-		//return nil
-
 		reg := prometheus.NewPedanticRegistry()
 		_ = reg.Register(collectors.NewBuildInfoCollector())
 		_ = reg.Register(collectors.NewGoCollector())
 		_ = reg.Register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
 
-		mainCache := cache.New(5*scrapeInterval, 10*scrapeInterval)
+		// cancelled on SIGINT or SIGTERM, which aborts running refreshes
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		shutdownTimeout, err := cmd.Flags().GetDuration("web.shutdown-timeout")
 
-		sugar.Infof("Start initial data collection")
+		if err != nil {
+			return err
+		}
 
-		ctx := context.Background()
 		errorCounter := collector.Counter(0)
 
 		sc := &collector.StatisticsCollector{
 			Logger:       sugar,
-			Cache:        mainCache,
 			ErrorCounter: &errorCounter,
 		}
 
 		_ = sc.Run(ctx)
 		_ = reg.Register(sc)
 
-		vc := &collector.VmanageCollector{
+		t := &targets{
 			Logger:       sugar,
-			Client:       vmClient,
-			Cache:        mainCache,
 			ErrorCounter: &errorCounter,
+			Registry:     reg,
+		}
+
+		if err := t.apply(ctx, cfg); err != nil {
+			return err
 		}
 
-		_ = vc.Run(ctx)
-		_ = reg.Register(vc)
+		reload := func() error {
+			cfg, err := loadConfig(cmd)
+
+			if err != nil {
+				return err
+			}
+
+			if err := t.apply(ctx, cfg); err != nil {
+				return err
+			}
+
+			sugar.Infow("Reloaded configuration")
+			return nil
+		}
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
 
-		sugar.Infof("Start collector threads")
-		scraper := time.NewTicker(scrapeInterval + scrapeInterval/2)
 		go func() {
-			for {
-				select {
-				case <-scraper.C:
-					go func() {
-						ctx, cancel := context.WithTimeout(ctx, scrapeInterval)
-						defer cancel()
-						_ = vc.Run(ctx)
-					}()
+			for range hup {
+				if err := reload(); err != nil {
+					sugar.Errorw("Error reloading configuration", "error", err)
 				}
 			}
 		}()
 
-		http.Handle(metricsPath, promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+		http.Handle(metricsPath, promhttp.HandlerFor(t, promhttp.HandlerOpts{}))
+		http.HandleFunc("/probe", t.probe)
 
-		http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-			max, _ := cmd.Flags().GetInt("scrape.max-errors")
+		http.HandleFunc("/-/reload", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				_, _ = w.Write([]byte("Only POST requests allowed"))
+				return
+			}
 
-			if errorCounter.Get() > int64(max) {
+			if err := reload(); err != nil {
+				sugar.Errorw("Error reloading configuration", "error", err)
 				w.WriteHeader(500)
-				_, _ = w.Write([]byte("Unhealthy"))
+				_, _ = w.Write([]byte(err.Error()))
 				return
 			}
 
 			_, _ = w.Write([]byte("OK"))
 		})
 
+		http.HandleFunc("/-/healthy", healthy)
+		http.HandleFunc("/-/ready", t.ready)
+		// kept for compatibility
+		http.HandleFunc("/health", t.ready)
+
 		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 			_, _ = w.Write([]byte(`
             <html>
@@ -168,28 +141,163 @@ var rootCmd = &cobra.Command{
             <body>
             <h1>Metrics</h1>
             <p><a href='` + metricsPath + `'>Metrics</a></p>
+            <p><a href='/probe?target=` + t.config().TargetList()[0].Name + `'>Probe</a></p>
             </body>
             </html>
         `))
 		})
 
-		sugar.Infof("Start listening for connections on %s", addr)
-		return http.ListenAndServe(addr, nil)
+		srv := &http.Server{Addr: addr}
+		serveErr := make(chan error, 1)
+
+		go func() {
+			sugar.Infof("Start listening for connections on %s", addr)
+			serveErr <- srv.ListenAndServe()
+		}()
+
+		select {
+		case err := <-serveErr:
+			t.stop()
+			return err
+		case <-ctx.Done():
+		}
+
+		sugar.Infow("Shutting down")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			sugar.Warnw("Error shutting down web server", "error", err)
+		}
+
+		t.stop()
+		sugar.Infow("Shutdown complete")
+
+		return nil
 	},
 }
 
+// loadConfig reads the configuration file, if any, on top of the command line flags.
+func loadConfig(cmd *cobra.Command) (*config.Config, error) {
+	f := cmd.Flags()
+	cfg := &config.Config{}
+
+	cfg.Name, _ = f.GetString("vmanage.name")
+	cfg.VManage.Endpoint, _ = f.GetString("vmanage.endpoint")
+	cfg.VManage.Endpoints, _ = f.GetStringSlice("vmanage.endpoints")
+	cfg.VManage.HealthInterval, _ = f.GetDuration("vmanage.health-interval")
+	cfg.VManage.Credentials = config.Credentials{UsernameEnv: userEnv, PasswordEnv: passwordEnv}
+	cfg.VManage.Auth, _ = f.GetString("vmanage.auth")
+	cfg.VManage.JWTDuration, _ = f.GetDuration("vmanage.jwt-duration")
+	cfg.VManage.TLSVerify, _ = f.GetBool("tls.verify")
+	cfg.VManage.Timeout, _ = f.GetDuration("vmanage.timeout")
+	cfg.VManage.PoolSize, _ = f.GetInt("vmanage.pool-size")
+	cfg.VManage.IdleTimeout, _ = f.GetDuration("vmanage.idle-timeout")
+	cfg.VManage.PageSize, _ = f.GetInt("vmanage.page-size")
+	cfg.VManage.RateLimit, _ = f.GetFloat64("vmanage.rate-limit")
+	cfg.VManage.RateBurst, _ = f.GetInt("vmanage.rate-burst")
+	cfg.VManage.Retries, _ = f.GetInt("vmanage.retries")
+	cfg.VManage.RetryWait, _ = f.GetDuration("vmanage.retry-wait")
+	cfg.VManage.RetryMaxWait, _ = f.GetDuration("vmanage.retry-max-wait")
+	cfg.VManage.BreakerThreshold, _ = f.GetInt("vmanage.breaker-threshold")
+	cfg.VManage.BreakerTimeout, _ = f.GetDuration("vmanage.breaker-timeout")
+	cfg.VManage.Tenants, _ = f.GetStringSlice("vmanage.tenants")
+	cfg.VManage.RecordDir, _ = f.GetString("vmanage.record-dir")
+	cfg.VManage.ReplayDir, _ = f.GetString("vmanage.replay-dir")
+
+	cfg.Scrape.Interval, _ = f.GetDuration("scrape.interval")
+	cfg.Scrape.Workers, _ = f.GetInt("scrape.workers")
+	cfg.Scrape.AdaptiveWorkers, _ = f.GetBool("scrape.adaptive-workers")
+	cfg.Scrape.SlowRequest, _ = f.GetDuration("scrape.slow-request")
+	cfg.Scrape.Bulk, _ = f.GetBool("scrape.bulk")
+	cfg.Scrape.MaxErrorRatio, _ = f.GetFloat64("scrape.max-error-ratio")
+	cfg.Scrape.MaxErrors, _ = f.GetInt("scrape.max-errors")
+	cfg.Scrape.ErrorWindow, _ = f.GetDuration("scrape.error-window")
+	cfg.Scrape.StaleIntervals, _ = f.GetInt("scrape.stale-intervals")
+
+	cfg.Collectors.Intervals = map[string]time.Duration{}
+	cfg.Collectors.AlarmsLookback, _ = f.GetDuration("alarms.lookback")
+
+	for _, name := range collector.Names() {
+		enabled, _ := f.GetBool("collector." + name)
+
+		if disabled, _ := f.GetBool("no-collector." + name); disabled {
+			enabled = false
+		}
+
+		if enabled {
+			cfg.Collectors.Enabled = append(cfg.Collectors.Enabled, name)
+		}
+
+		if d, _ := f.GetDuration("collector." + name + ".interval"); d > 0 {
+			cfg.Collectors.Intervals[name] = d
+		}
+	}
+
+	if path, _ := f.GetString("config.file"); path != "" {
+		var err error
+
+		if cfg, err = config.Load(path, *cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
 // Execute runs root command
 func Execute() {
+	rootCmd.Flags().String("config.file", "", "Path to YAML configuration file, overrides flags. Reloaded on SIGHUP or POST /-/reload.")
+
 	rootCmd.Flags().String("vmanage.endpoint", "", "URL of vManage API")
-	_ = rootCmd.MarkFlagRequired("vmanage.endpoint")
+	rootCmd.Flags().StringSlice("vmanage.endpoints", nil, "URLs of further nodes of a vManage cluster, requests fail over to them if the endpoint is unavailable")
+	rootCmd.Flags().Duration("vmanage.health-interval", time.Minute, "Interval at which preferred vManage nodes are checked after a failover")
+	rootCmd.Flags().String("vmanage.name", "", "Name of vManage instance exported as label vmanage (default host of endpoint)")
+	rootCmd.Flags().String("vmanage.auth", "auto", "Login method: session, jwt (vManage 20.12+) or auto")
+	rootCmd.Flags().Duration("vmanage.jwt-duration", 30*time.Minute, "Requested lifetime of JWTs, they are refreshed before they expire")
+	rootCmd.Flags().Duration("vmanage.timeout", 10*time.Second, "Timeout of vManage API requests")
+	rootCmd.Flags().Int("vmanage.pool-size", 10, "Max number of connections to vManage")
+	rootCmd.Flags().Duration("vmanage.idle-timeout", 90*time.Second, "Close idle connections to vManage after this duration")
+	rootCmd.Flags().Int("vmanage.page-size", 1000, "Number of records to request per page from paginated APIs")
+	rootCmd.Flags().Float64("vmanage.rate-limit", 0, "Max requests per second to vManage, 0 for no limit")
+	rootCmd.Flags().Int("vmanage.rate-burst", 10, "Number of requests which may exceed the rate limit at once")
+	rootCmd.Flags().Int("vmanage.retries", 3, "Number of retries of requests failing while vManage is unavailable")
+	rootCmd.Flags().Duration("vmanage.retry-wait", 500*time.Millisecond, "Initial wait before a retry, doubled with every attempt")
+	rootCmd.Flags().Duration("vmanage.retry-max-wait", 10*time.Second, "Max wait before a retry, also limits Retry-After")
+	rootCmd.Flags().Int("vmanage.breaker-threshold", 5, "Consecutive failed requests after which requests to vManage are stopped, 0 to disable")
+	rootCmd.Flags().Duration("vmanage.breaker-timeout", 30*time.Second, "Time requests are stopped before vManage is tried again")
+	rootCmd.Flags().StringSlice("vmanage.tenants", nil, "Names or ids of the tenants to collect in multi-tenant mode, * for all (requires a provider login)")
+	rootCmd.Flags().String("vmanage.record-dir", "", "Record vManage responses with secrets redacted to this directory")
+	rootCmd.Flags().String("vmanage.replay-dir", "", "Serve vManage responses recorded with --vmanage.record-dir from this directory instead of requesting vManage")
 
 	rootCmd.Flags().String("web.listen-address", ":9910", "Address on which to expose metrics and web interface.")
 	rootCmd.Flags().String("web.metrics-path", "/metrics", "Path under which to expose metrics.")
+	rootCmd.Flags().Duration("web.shutdown-timeout", 10*time.Second, "Time to wait for open requests on shutdown.")
 
 	rootCmd.Flags().Bool("tls.verify", true, "Verify certificate.")
 
 	rootCmd.Flags().Duration("scrape.interval", 15*time.Second, "Polling interval")
-	rootCmd.Flags().Int("scrape.max-errors", 25, "Max scrape errors before reporting exporter as unhealthy")
+	rootCmd.Flags().Int("scrape.workers", 5, "Number of devices refreshed concurrently")
+	rootCmd.Flags().Bool("scrape.adaptive-workers", false, "Reduce workers while vManage throttles or responds slowly, up to scrape.workers")
+	rootCmd.Flags().Duration("scrape.slow-request", 5*time.Second, "Request duration considered slow by adaptive workers")
+	rootCmd.Flags().Bool("scrape.bulk", false, "Fetch statistics of all devices at once instead of per device")
+	rootCmd.Flags().Duration("alarms.lookback", 24*time.Hour, "Only export alarms raised within this duration")
+	rootCmd.Flags().Float64("scrape.max-error-ratio", 0.2, "Max fraction of requests failed within scrape.error-window before reporting a target as not ready")
+	rootCmd.Flags().Int("scrape.max-errors", 0, "Max failed requests within scrape.error-window before reporting a target as not ready, 0 disables the check")
+	_ = rootCmd.Flags().MarkDeprecated("scrape.max-errors", "use --scrape.max-error-ratio instead")
+	rootCmd.Flags().Duration("scrape.error-window", 5*time.Minute, "Sliding window in which failed requests are counted")
+	rootCmd.Flags().Int("scrape.stale-intervals", 3, "Report a target as not ready if its device list was not refreshed within this many scrape intervals")
+
+	for _, name := range collector.Names() {
+		rootCmd.Flags().Bool("collector."+name, collector.EnabledByDefault(name), fmt.Sprintf("Enable the %s collector", name))
+		rootCmd.Flags().Bool("no-collector."+name, false, fmt.Sprintf("Disable the %s collector", name))
+		rootCmd.Flags().Duration("collector."+name+".interval", 0, fmt.Sprintf("Refresh interval of the %s collector (default scrape.interval)", name))
+	}
 
 	if err := rootCmd.Execute(); err != nil {
 		_, _ = fmt.Fprintln(os.Stderr, err)
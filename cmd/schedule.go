@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"github.com/zebbra/vmanage-exporter/internal/lib/config"
+	"math/rand"
+	"time"
+)
+
+// jitter is the maximum fraction of the interval a refresh is delayed by,
+// so the refreshes of different collectors and targets do not hit vManage at once.
+const jitter = 0.1
+
+// schedule starts a timer for the device list, and the tenant list in multi-tenant mode, at the
+// scrape interval and one for each sub-collector at its own interval. They run until e.done is closed.
+func (e *exporter) schedule(cfg *config.Target) {
+	done := make(chan struct{})
+	e.done = done
+
+	e.every(done, "device_list", cfg.Scrape.Interval, func(ctx context.Context) {
+		_ = e.refreshTenants(ctx)
+		_ = e.current().RefreshDevices(ctx)
+	})
+
+	for name, interval := range intervals(cfg) {
+		name := name
+
+		e.every(done, name, interval, func(ctx context.Context) {
+			if err := e.current().RunCollector(ctx, name); err != nil {
+				e.Logger.Warnw("Error refreshing collector", "collector", name, "error", err)
+			}
+		})
+	}
+}
+
+// every calls run after each interval plus jitter with a timeout of the interval.
+// A refresh is skipped if the previous one of the same name is still running.
+func (e *exporter) every(done <-chan struct{}, name string, interval time.Duration, run func(ctx context.Context)) {
+	timer := time.NewTimer(withJitter(interval))
+
+	go func() {
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-timer.C:
+				timer.Reset(withJitter(interval))
+
+				if !e.begin() {
+					return
+				}
+
+				go func() {
+					defer e.running.Done()
+
+					if !e.tryRun(name, func() {
+						ctx, done := e.acquire()
+						defer done()
+
+						ctx, cancel := context.WithTimeout(ctx, interval)
+						defer cancel()
+						run(ctx)
+					}) {
+						e.Logger.Warnw("Skipped refresh, previous one is still running", "collector", name)
+						e.Status.ObserveSkipped(name)
+					}
+				}()
+			}
+		}
+	}()
+}
+
+// tryRun calls run unless a refresh of the same name is in flight and reports whether it did.
+func (e *exporter) tryRun(name string, run func()) bool {
+	e.inflightMu.Lock()
+
+	if e.inflight[name] {
+		e.inflightMu.Unlock()
+		return false
+	}
+
+	e.inflight[name] = true
+	e.inflightMu.Unlock()
+
+	defer func() {
+		e.inflightMu.Lock()
+		delete(e.inflight, name)
+		e.inflightMu.Unlock()
+	}()
+
+	run()
+	return true
+}
+
+func withJitter(interval time.Duration) time.Duration {
+	return interval + time.Duration(rand.Int63n(int64(float64(interval)*jitter)+1))
+}
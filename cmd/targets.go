@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/zebbra/vmanage-exporter/internal/lib/collector"
+	"github.com/zebbra/vmanage-exporter/internal/lib/config"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+)
+
+// targets manages an exporter per configured vManage instance and gathers
+// their metrics together with the metrics of the process.
+type targets struct {
+	Logger       *zap.SugaredLogger
+	ErrorCounter *collector.Counter
+	Registry     *prometheus.Registry
+
+	// applyMu serializes reloads, mu guards the current state.
+	applyMu   sync.Mutex
+	mu        sync.RWMutex
+	cfg       *config.Config
+	exporters map[string]*exporter
+	ctx       context.Context
+}
+
+func (t *targets) config() *config.Config {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.cfg
+}
+
+// apply creates, updates and removes exporters to match cfg. New exporters are refreshed
+// before they are started, synchronously on startup and in background on reload.
+// Exporters are built and log in without holding the lock, so metrics can be gathered during a reload.
+// Targets which fail to apply keep running with their previous configuration, config returns the one in effect.
+func (t *targets) apply(ctx context.Context, cfg *config.Config) error {
+	t.applyMu.Lock()
+	defer t.applyMu.Unlock()
+
+	t.mu.Lock()
+	initial := t.exporters == nil
+
+	if initial {
+		t.exporters = map[string]*exporter{}
+		t.ctx = ctx
+	}
+
+	current := map[string]*exporter{}
+
+	for name, e := range t.exporters {
+		current[name] = e
+	}
+	t.mu.Unlock()
+
+	var errs []string
+	var stop []*exporter
+	var start []*exporter
+	exporters := map[string]*exporter{}
+
+	for _, tc := range cfg.TargetList() {
+		tc := tc
+		e, ok := current[tc.Name]
+
+		if ok && e.config().Scrape.ProbeOnly == tc.Scrape.ProbeOnly {
+			if err := e.apply(&tc); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", tc.Name, err))
+			}
+
+			exporters[tc.Name] = e
+			continue
+		}
+
+		n, err := newExporter(t.ctx, &tc, t.Logger, t.ErrorCounter)
+
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", tc.Name, err))
+
+			// keep the exporter of the previous configuration
+			if ok {
+				exporters[tc.Name] = e
+			}
+
+			continue
+		}
+
+		if ok {
+			stop = append(stop, e)
+		}
+
+		exporters[tc.Name] = n
+
+		if !tc.Scrape.ProbeOnly {
+			start = append(start, n)
+		}
+	}
+
+	for name, e := range current {
+		if _, ok := exporters[name]; !ok {
+			e.Logger.Infof("Stop collection of removed target")
+			stop = append(stop, e)
+		}
+	}
+
+	// the configuration in effect: targets which failed to apply keep their previous settings or are left out
+	effective := &config.Config{}
+
+	for _, tc := range cfg.TargetList() {
+		if e, ok := exporters[tc.Name]; ok {
+			effective.Targets = append(effective.Targets, *e.config())
+		}
+	}
+
+	t.mu.Lock()
+	t.exporters = exporters
+	t.cfg = effective
+	t.mu.Unlock()
+
+	for _, e := range stop {
+		e.Stop()
+	}
+
+	var wg sync.WaitGroup
+
+	for _, e := range start {
+		e := e
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			e.Logger.Infof("Start initial data collection")
+			e.Run()
+			e.Start()
+		}()
+	}
+
+	if initial {
+		wg.Wait()
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// stop stops all exporters concurrently and logs out of vManage.
+func (t *targets) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var wg sync.WaitGroup
+
+	for _, e := range t.exporters {
+		e := e
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			e.Stop()
+		}()
+	}
+
+	wg.Wait()
+	t.exporters = map[string]*exporter{}
+}
+
+// Gather merges the metrics of the process and all targets which are refreshed periodically.
+func (t *targets) Gather() ([]*dto.MetricFamily, error) {
+	t.mu.RLock()
+	gatherers := prometheus.Gatherers{t.Registry}
+
+	for _, e := range t.exporters {
+		if !e.config().Scrape.ProbeOnly {
+			gatherers = append(gatherers, e.Registry)
+		}
+	}
+	t.mu.RUnlock()
+
+	return gatherers.Gather()
+}
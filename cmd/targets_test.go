@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"github.com/zebbra/vmanage-exporter/internal/lib/collector"
+	"github.com/zebbra/vmanage-exporter/internal/lib/config"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage/vmanagetest"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func TestApplyPartialFailure(t *testing.T) {
+	srv := vmanagetest.NewServer()
+	t.Cleanup(srv.Close)
+
+	errorCounter := collector.Counter(0)
+	tg := &targets{Logger: zap.NewNop().Sugar(), ErrorCounter: &errorCounter}
+	t.Cleanup(tg.stop)
+
+	a, b := testTarget(t, "a", srv), testTarget(t, "b", srv)
+
+	if err := tg.apply(context.Background(), &config.Config{Targets: []config.Target{*a, *b}}); err != nil {
+		t.Fatalf("Error applying configuration: %s", err)
+	}
+
+	a.Scrape.Interval = 2 * time.Minute
+	b.VManage.Credentials.PasswordEnv = "TEST_VMANAGE_MISSING"
+
+	if err := tg.apply(context.Background(), &config.Config{Targets: []config.Target{*a, *b}}); err == nil {
+		t.Fatal("Expected configuration with missing credentials to fail")
+	}
+
+	cfg := tg.config().TargetList()
+
+	if len(cfg) != 2 || cfg[0].Scrape.Interval != 2*time.Minute || cfg[1].VManage.Credentials.PasswordEnv != "TEST_VMANAGE_PASSWORD" {
+		t.Errorf("Expected the new configuration of a and the previous one of b, got %+v", cfg)
+	}
+}
+
+func TestReloadWaitsForRefresh(t *testing.T) {
+	e, srv := newTestExporter(t)
+
+	ctx, done := e.acquire()
+	cfg := *e.config()
+	cfg.VManage.Timeout = 2 * time.Second
+	applied := make(chan error)
+
+	go func() {
+		applied <- e.apply(&cfg)
+	}()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected refresh using the replaced client to be cancelled")
+	}
+
+	if n := srv.Sessions(); n != 2 {
+		t.Errorf("Expected the replaced client to stay logged in while a refresh uses it, got %d sessions", n)
+	}
+
+	done()
+
+	if err := <-applied; err != nil {
+		t.Fatalf("Error applying configuration: %s", err)
+	}
+
+	if n := srv.Sessions(); n != 1 {
+		t.Errorf("Expected the replaced client to be logged out, got %d sessions", n)
+	}
+}
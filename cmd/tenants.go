@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zebbra/vmanage-exporter/internal/lib/collector"
+	"github.com/zebbra/vmanage-exporter/internal/lib/config"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+	"reflect"
+	"time"
+)
+
+// errNoTenants is returned by the collectors of a multi-tenant target if none of the configured tenants was found
+// or the tenant list has not been fetched yet.
+var errNoTenants = errors.New("No tenants selected")
+
+// tenantCollector collects the devices of a tenant of a multi-tenant vManage,
+// or of the whole vManage if tenant is empty.
+type tenantCollector struct {
+	tenant vmanage.Tenant
+	*collector.VmanageCollector
+}
+
+// collectorSet runs the collector of a target or, in multi-tenant mode, the collectors of its tenants.
+// The outcome of multi-tenant refreshes is recorded by status, as the tenant collectors do not record it.
+type collectorSet struct {
+	multiTenant bool
+	status      *collector.Status
+	collectors  []*tenantCollector
+}
+
+// newCollectorSet builds the collector of the target, or a collector per tenant if tenants are configured.
+// cacheFor returns the cache of a tenant, with an empty tenant id for targets without tenants.
+// The outcome of the runs is recorded in status, if set.
+func (e *exporter) newCollectorSet(cfg *config.Target, client *vmanage.Client, tenants []vmanage.Tenant, cacheFor func(tenantID string) *cache.Cache, status *collector.Status) *collectorSet {
+	if len(cfg.VManage.Tenants) == 0 {
+		return &collectorSet{
+			collectors: []*tenantCollector{{VmanageCollector: e.newCollector(cfg, client, cacheFor(""), status)}},
+		}
+	}
+
+	s := &collectorSet{multiTenant: true, status: status}
+
+	for _, t := range tenants {
+		c := e.newCollector(cfg, client.ForTenant(t.TenantID), cacheFor(t.TenantID), nil)
+		c.Logger = e.Logger.With("tenant", tenantName(t))
+
+		s.collectors = append(s.collectors, &tenantCollector{tenant: t, VmanageCollector: c})
+	}
+
+	return s
+}
+
+// Run refreshes the device list and the data of all enabled sub-collectors of every tenant.
+func (s *collectorSet) Run(ctx context.Context) error {
+	if !s.multiTenant {
+		return s.collectors[0].Run(ctx)
+	}
+
+	if err := s.RefreshDevices(ctx); err != nil {
+		return err
+	}
+
+	var errs []error
+
+	if len(s.collectors) > 0 {
+		for _, name := range s.collectors[0].Collectors {
+			if err := s.RunCollector(ctx, name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%d collectors failed, first error: %w", len(errs), errs[0])
+	}
+
+	return nil
+}
+
+func (s *collectorSet) RefreshDevices(ctx context.Context) error {
+	return s.each("device_list", func(c *tenantCollector) error {
+		return c.RefreshDevices(ctx)
+	})
+}
+
+func (s *collectorSet) RunCollector(ctx context.Context, name string) error {
+	return s.each(name, func(c *tenantCollector) error {
+		return c.RunCollector(ctx, name)
+	})
+}
+
+// each calls run for every collector and records the overall outcome as name in multi-tenant mode.
+// It returns the first error, or errNoTenants if no tenant is selected.
+func (s *collectorSet) each(name string, run func(c *tenantCollector) error) error {
+	startTime := time.Now()
+	var firstErr error
+
+	if s.multiTenant && len(s.collectors) == 0 {
+		firstErr = errNoTenants
+	}
+
+	for _, c := range s.collectors {
+		if err := run(c); err != nil && firstErr == nil {
+			firstErr = err
+
+			if s.multiTenant {
+				firstErr = fmt.Errorf("Tenant %s: %w", tenantName(c.tenant), err)
+			}
+		}
+	}
+
+	if s.multiTenant {
+		s.status.ObserveCollector(name, time.Since(startTime), firstErr)
+	}
+
+	return firstErr
+}
+
+// Collect sends the metrics of all collectors, labelled with the tenant in multi-tenant mode.
+func (s *collectorSet) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range s.collectors {
+		if !s.multiTenant {
+			c.Collect(ch)
+			continue
+		}
+
+		withLabels(prometheus.Labels{"tenant": tenantName(c.tenant)}, c.VmanageCollector).Collect(ch)
+	}
+}
+
+// withLabels returns a collector adding labels to the metrics of c. The wrapping collector
+// of client_golang is only available through a registerer, which captures it here.
+func withLabels(labels prometheus.Labels, c prometheus.Collector) prometheus.Collector {
+	r := &captureRegisterer{}
+	_ = prometheus.WrapRegistererWith(labels, r).Register(c)
+
+	return r.collector
+}
+
+type captureRegisterer struct {
+	collector prometheus.Collector
+}
+
+func (r *captureRegisterer) Register(c prometheus.Collector) error {
+	r.collector = c
+	return nil
+}
+
+func (r *captureRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		r.collector = c
+	}
+}
+
+func (r *captureRegisterer) Unregister(c prometheus.Collector) bool {
+	return false
+}
+
+// refreshTenants fetches the tenants of a multi-tenant vManage and rebuilds the collectors
+// if the selected tenants changed. The caches of removed tenants are dropped.
+func (e *exporter) refreshTenants(ctx context.Context) error {
+	e.mu.RLock()
+	cfg := e.cfg
+	client := e.client
+	e.mu.RUnlock()
+
+	if len(cfg.VManage.Tenants) == 0 {
+		return nil
+	}
+
+	startTime := time.Now()
+	all, err := client.Tenant(ctx)
+	e.Status.ObserveCollector("tenant_list", time.Since(startTime), err)
+
+	if err != nil {
+		e.Logger.Errorw(
+			"Error fetching tenant list",
+			"error", err,
+		)
+
+		e.ErrorCounter.Inc()
+		return err
+	}
+
+	tenants, missing := selectTenants(all, cfg.VManage.Tenants)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if reflect.DeepEqual(tenants, e.tenants) {
+		return nil
+	}
+
+	e.Logger.Infow("Tenant list changed", "count", len(tenants), "total", len(all))
+
+	if len(missing) > 0 {
+		e.Logger.Warnw("Configured tenants not found", "tenants", missing)
+	}
+
+	e.tenants = tenants
+	e.collectors = e.newCollectorSet(e.cfg, e.client, tenants, e.tenantCache(e.cfg), e.Status)
+
+	for id := range e.tenantCaches {
+		if !containsTenant(tenants, id) {
+			delete(e.tenantCaches, id)
+		}
+	}
+
+	return nil
+}
+
+// tenantCache returns a function returning the cache of a tenant, which is created on first use.
+// The caller has to hold e.mu.
+func (e *exporter) tenantCache(cfg *config.Target) func(tenantID string) *cache.Cache {
+	return func(tenantID string) *cache.Cache {
+		if tenantID == "" {
+			return e.Cache
+		}
+
+		c, ok := e.tenantCaches[tenantID]
+
+		if !ok {
+			c = cache.New(5*cfg.Scrape.Interval, 10*cfg.Scrape.Interval)
+			e.tenantCaches[tenantID] = c
+		}
+
+		return c
+	}
+}
+
+// selectTenants returns the tenants matching the configured names or ids, all tenants for "*",
+// and the configured tenants which were not found.
+func selectTenants(all []vmanage.Tenant, selected []string) ([]vmanage.Tenant, []string) {
+	if contains(selected, "*") {
+		return all, nil
+	}
+
+	var res []vmanage.Tenant
+	found := map[string]bool{}
+
+	for _, t := range all {
+		for _, s := range selected {
+			if s == t.Name || s == t.TenantID {
+				res = append(res, t)
+				found[s] = true
+				break
+			}
+		}
+	}
+
+	var missing []string
+
+	for _, s := range selected {
+		if !found[s] {
+			missing = append(missing, s)
+		}
+	}
+
+	return res, missing
+}
+
+func containsTenant(tenants []vmanage.Tenant, id string) bool {
+	for _, t := range tenants {
+		if t.TenantID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// tenantName returns the value of the tenant label: the name of the tenant or its id.
+func tenantName(t vmanage.Tenant) string {
+	if t.Name != "" {
+		return t.Name
+	}
+
+	return t.TenantID
+}
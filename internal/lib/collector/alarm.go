@@ -0,0 +1,104 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+	"math"
+)
+
+func init() {
+	registerCollector("alarms", false, func(c *VmanageCollector) SubCollector { return &alarmCollector{c} })
+}
+
+// alarmCollector exports the active alarms raised by vManage within the lookback window.
+type alarmCollector struct {
+	*VmanageCollector
+}
+
+func (c *alarmCollector) Run(ctx context.Context, devices map[string]vmanage.Device) error {
+	c.Logger.Infow("Refresh alarms")
+
+	res, err := c.Client.Alarm(ctx, &vmanage.AlarmListOptions{
+		Active:     true,
+		LastNHours: int(math.Ceil(c.AlarmLookback.Hours())),
+	})
+
+	if err != nil {
+		c.Logger.Errorw(
+			"Error fetching alarms",
+			"error", err,
+		)
+
+		c.ErrorCounter.Inc()
+		return err
+	}
+
+	c.Logger.Infow("Successfully refreshed alarms", "count", len(res))
+	c.Cache.Set("alarms", res, c.expiration("alarms"))
+
+	return nil
+}
+
+func (c *alarmCollector) Collect(devices map[string]vmanage.Device, ch chan<- prometheus.Metric) {
+	alarms := []vmanage.Alarm{}
+
+	if a, found := c.Cache.Get("alarms"); found {
+		alarms = a.([]vmanage.Alarm)
+	} else {
+		return
+	}
+
+	type alarmKey struct {
+		Severity string
+		Type     string
+		Device   string
+		Site     string
+	}
+
+	active := map[alarmKey]int{}
+	severities := map[string]int{}
+
+	for _, a := range alarms {
+		if !a.Active {
+			continue
+		}
+
+		device := a.HostName
+
+		if device == "" {
+			device = a.SystemIP
+		}
+
+		active[alarmKey{a.Severity, a.Type, device, a.SiteID}]++
+		severities[a.Severity]++
+	}
+
+	for k, n := range active {
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				"vmanage_alarm_active",
+				"Number of active alarms",
+				[]string{"severity", "type", "device", "site"},
+				nil,
+			),
+			prometheus.GaugeValue,
+			float64(n),
+			k.Severity, k.Type, k.Device, k.Site,
+		)
+	}
+
+	for severity, n := range severities {
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				"vmanage_alarms_active",
+				"Number of active alarms by severity",
+				[]string{"severity"},
+				nil,
+			),
+			prometheus.GaugeValue,
+			float64(n),
+			severity,
+		)
+	}
+}
@@ -0,0 +1,167 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+)
+
+func init() {
+	registerCollector("app_route", false, func(c *VmanageCollector) SubCollector { return &appRouteCollector{c} })
+}
+
+// appRouteCollector exports the SLA measurements of application-aware routing for every tunnel.
+type appRouteCollector struct {
+	*VmanageCollector
+}
+
+func (c *appRouteCollector) Run(ctx context.Context, devices map[string]vmanage.Device) error {
+	return c.forEachDevice(ctx, devices, "app route statistics", func(deviceID string) error {
+		c.Logger.Infow("Refresh app route statistics", "DeviceID", deviceID)
+
+		res, err := c.Client.DeviceAppRouteStatistics(
+			ctx,
+			&vmanage.DeviceAppRouteStatisticsListOptions{DeviceID: deviceID},
+		)
+
+		if err != nil {
+			return err
+		}
+
+		c.Cache.Set(fmt.Sprintf("approute_%s", deviceID), res, c.expiration("app_route"))
+		return nil
+	})
+}
+
+func (c *appRouteCollector) Collect(devices map[string]vmanage.Device, ch chan<- prometheus.Metric) {
+	for _, d := range devices {
+		stats, found := c.Cache.Get(fmt.Sprintf("approute_%s", d.DeviceID))
+
+		if !found {
+			continue
+		}
+
+		for _, t := range appRouteTunnels(stats.([]vmanage.DeviceAppRouteStatistics)) {
+			tunnelLabels := tunnelLabels(d, t)
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					"vmanage_tunnel_latency_ms",
+					"Mean latency of tunnel",
+					tunnelLabels.Labels,
+					nil,
+				),
+				prometheus.GaugeValue,
+				t.MeanLatency,
+				tunnelLabels.Values...,
+			)
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					"vmanage_tunnel_loss_percent",
+					"Mean packet loss of tunnel",
+					tunnelLabels.Labels,
+					nil,
+				),
+				prometheus.GaugeValue,
+				t.MeanLoss,
+				tunnelLabels.Values...,
+			)
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					"vmanage_tunnel_jitter_ms",
+					"Mean jitter of tunnel",
+					tunnelLabels.Labels,
+					nil,
+				),
+				prometheus.GaugeValue,
+				t.MeanJitter,
+				tunnelLabels.Values...,
+			)
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					"vmanage_tunnel_tx_packets",
+					"Packets sent through tunnel within the measurement interval",
+					tunnelLabels.Labels,
+					nil,
+				),
+				prometheus.GaugeValue,
+				float64(t.TxPkts),
+				tunnelLabels.Values...,
+			)
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					"vmanage_tunnel_rx_packets",
+					"Packets received through tunnel within the measurement interval",
+					tunnelLabels.Labels,
+					nil,
+				),
+				prometheus.GaugeValue,
+				float64(t.RxPkts),
+				tunnelLabels.Values...,
+			)
+		}
+	}
+}
+
+// appRouteTunnels merges the buckets of each tunnel into a single entry, summing up the packet counts.
+// The mean values are taken from the newest bucket.
+func appRouteTunnels(stats []vmanage.DeviceAppRouteStatistics) []vmanage.DeviceAppRouteStatistics {
+	var tunnels []vmanage.DeviceAppRouteStatistics
+	index := map[string]int{}
+
+	for _, s := range stats {
+		key := fmt.Sprintf("%s|%s|%s|%s|%s|%s|%s", s.RemoteSystemIP, s.SrcIP, s.DstIP, s.LocalColor, s.RemoteColor, s.Proto, s.SLAClassIndex)
+
+		i, ok := index[key]
+
+		if !ok {
+			index[key] = len(tunnels)
+			tunnels = append(tunnels, s)
+			continue
+		}
+
+		t := &tunnels[i]
+		s.TxPkts += t.TxPkts
+		s.RxPkts += t.RxPkts
+
+		if s.Lastupdated > t.Lastupdated || s.Lastupdated == t.Lastupdated && s.Index > t.Index {
+			*t = s
+		} else {
+			t.TxPkts, t.RxPkts = s.TxPkts, s.RxPkts
+		}
+	}
+
+	return tunnels
+}
+
+func tunnelLabels(d vmanage.Device, s vmanage.DeviceAppRouteStatistics) struct {
+	Labels []string
+	Values []string
+} {
+	l := []string{"DeviceID", "Hostname", "LocalSystemIP", "RemoteSystemIP", "SrcIP", "DstIP", "LocalColor", "RemoteColor", "Encapsulation", "SLAClass"}
+	v := []string{
+		d.DeviceID,
+		d.Hostname,
+		d.SystemIP,
+		s.RemoteSystemIP,
+		s.SrcIP,
+		s.DstIP,
+		s.LocalColor,
+		s.RemoteColor,
+		s.Proto,
+		s.SLAClassIndex,
+	}
+
+	return struct {
+		Labels []string
+		Values []string
+	}{
+		Labels: l,
+		Values: v,
+	}
+}
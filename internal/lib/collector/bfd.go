@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+)
+
+func init() {
+	registerCollector("bfd", false, func(c *VmanageCollector) SubCollector { return &bfdCollector{c} })
+}
+
+// bfdCollector exports the BFD sessions of the devices.
+type bfdCollector struct {
+	*VmanageCollector
+}
+
+func (c *bfdCollector) Run(ctx context.Context, devices map[string]vmanage.Device) error {
+	if c.Bulk {
+		c.Logger.Infow("Refresh BFD sessions in bulk")
+		return c.runBulk(ctx, devices)
+	}
+
+	return c.forEachDevice(ctx, devices, "BFD sessions", func(deviceID string) error {
+		c.Logger.Infow("Refresh BFD sessions", "DeviceID", deviceID)
+
+		res, err := c.Client.DeviceBFDSessions(
+			ctx,
+			&vmanage.DeviceBFDSessionListOptions{DeviceID: deviceID},
+		)
+
+		if err != nil {
+			return err
+		}
+
+		c.Cache.Set(fmt.Sprintf("bfd_%s", deviceID), res, c.expiration("bfd"))
+		return nil
+	})
+}
+
+// runBulk fetches the BFD sessions of all devices with a single paginated query.
+func (c *bfdCollector) runBulk(ctx context.Context, devices map[string]vmanage.Device) error {
+	res, err := c.Client.DeviceStateBFDSessions(ctx)
+
+	if err != nil {
+		c.Logger.Warnw(
+			"Error fetching BFD sessions in bulk",
+			"error", err,
+		)
+
+		c.ErrorCounter.Inc()
+		return err
+	}
+
+	ids := deviceIDs(devices)
+	records := map[string][]vmanage.DeviceBFDSession{}
+
+	for _, r := range res {
+		if deviceID, ok := ids[r.VdeviceName]; ok {
+			records[deviceID] = append(records[deviceID], r)
+		}
+	}
+
+	for deviceID := range devices {
+		c.Cache.Set(fmt.Sprintf("bfd_%s", deviceID), records[deviceID], c.expiration("bfd"))
+	}
+
+	return nil
+}
+
+func (c *bfdCollector) Collect(devices map[string]vmanage.Device, ch chan<- prometheus.Metric) {
+	for _, d := range devices {
+		// bfd sessions
+		if sessions, found := c.Cache.Get(fmt.Sprintf("bfd_%s", d.DeviceID)); found {
+			for _, s := range sessions.([]vmanage.DeviceBFDSession) {
+				bfdLabels := bfdSessionLabels(d, s)
+
+				ch <- prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						"vmanage_bfd_session_state",
+						"State of BFD session (1 = up)",
+						bfdLabels.Labels,
+						nil,
+					),
+					prometheus.GaugeValue,
+					boolValue(s.IsUp()),
+					bfdLabels.Values...,
+				)
+
+				if s.IsUp() && s.UptimeDate > 0 {
+					ch <- prometheus.MustNewConstMetric(
+						prometheus.NewDesc(
+							"vmanage_bfd_session_uptime_seconds",
+							"Uptime of BFD session",
+							bfdLabels.Labels,
+							nil,
+						),
+						prometheus.GaugeValue,
+						uptime(s.UptimeDate)/1000,
+						bfdLabels.Values...,
+					)
+				}
+
+				ch <- prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						"vmanage_bfd_session_transitions",
+						"Number of BFD session state transitions",
+						bfdLabels.Labels,
+						nil,
+					),
+					prometheus.CounterValue,
+					float64(s.Transitions),
+					bfdLabels.Values...,
+				)
+
+				ch <- prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						"vmanage_bfd_session_hello_interval_seconds",
+						"Configured BFD hello interval",
+						bfdLabels.Labels,
+						nil,
+					),
+					prometheus.GaugeValue,
+					float64(s.TxInterval)/1000,
+					bfdLabels.Values...,
+				)
+
+				ch <- prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						"vmanage_bfd_session_multiplier",
+						"Configured BFD detect multiplier",
+						bfdLabels.Labels,
+						nil,
+					),
+					prometheus.GaugeValue,
+					float64(s.DetectMultiplier),
+					bfdLabels.Values...,
+				)
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+)
+
+// BreakerCollector exports the state of the circuit breaker of a vManage client.
+type BreakerCollector struct {
+	Breaker *vmanage.Breaker
+}
+
+func (c *BreakerCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *BreakerCollector) Collect(ch chan<- prometheus.Metric) {
+	state := c.Breaker.State()
+
+	for _, s := range []vmanage.BreakerState{vmanage.BreakerClosed, vmanage.BreakerOpen, vmanage.BreakerHalfOpen} {
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				"vmanage_exporter_circuit_breaker_state",
+				"State of the circuit breaker protecting vManage",
+				[]string{"state"},
+				nil,
+			),
+			prometheus.GaugeValue,
+			boolValue(s == state),
+			s.String(),
+		)
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc(
+			"vmanage_exporter_circuit_breaker_opened_total",
+			"Number of times the circuit breaker opened",
+			[]string{},
+			nil,
+		),
+		prometheus.CounterValue,
+		float64(c.Breaker.Opened()),
+	)
+}
@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+)
+
+func init() {
+	registerCollector("certificates", false, func(c *VmanageCollector) SubCollector { return &certificatesCollector{c} })
+}
+
+// certificatesCollector exports the validity and expiry of the device certificates.
+type certificatesCollector struct {
+	*VmanageCollector
+}
+
+func (c *certificatesCollector) Run(ctx context.Context, devices map[string]vmanage.Device) error {
+	c.Logger.Infow("Refresh certificates")
+
+	// certificates reference devices by system ip
+	certificates := map[string]vmanage.CertificateRecord{}
+
+	for _, fetch := range []func(context.Context) ([]vmanage.CertificateRecord, error){
+		c.Client.CertificateController,
+		c.Client.CertificateRecord,
+	} {
+		res, err := fetch(ctx)
+
+		if err != nil {
+			c.Logger.Warnw(
+				"Error fetching certificates",
+				"error", err,
+			)
+
+			c.ErrorCounter.Inc()
+			return err
+		}
+
+		for _, r := range res {
+			if r.SystemIP != "" {
+				certificates[r.SystemIP] = r
+			}
+		}
+	}
+
+	c.Cache.Set("certificates", certificates, c.expiration("certificates"))
+	return nil
+}
+
+func (c *certificatesCollector) Collect(devices map[string]vmanage.Device, ch chan<- prometheus.Metric) {
+	certificates := map[string]vmanage.CertificateRecord{}
+
+	if cr, found := c.Cache.Get("certificates"); found {
+		certificates = cr.(map[string]vmanage.CertificateRecord)
+	}
+
+	for _, d := range devices {
+		deviceLabels := deviceLabels(d)
+
+		// certificate
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				"vmanage_device_certificate_valid",
+				"Validity of device certificate",
+				deviceLabels.Labels,
+				nil,
+			),
+			prometheus.GaugeValue,
+			boolValue(d.HasValidCertificate()),
+			deviceLabels.Values...,
+		)
+
+		if cr, found := certificates[d.SystemIP]; found {
+			if expiry, ok := cr.Expiry(); ok {
+				ch <- prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						"vmanage_device_certificate_expiry_timestamp_seconds",
+						"Expiration date of device certificate",
+						deviceLabels.Labels,
+						nil,
+					),
+					prometheus.GaugeValue,
+					float64(expiry.Unix()),
+					deviceLabels.Values...,
+				)
+			}
+		}
+	}
+}
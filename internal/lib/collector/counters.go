@@ -0,0 +1,129 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+)
+
+func init() {
+	registerCollector("counters", false, func(c *VmanageCollector) SubCollector { return &countersCollector{c} })
+}
+
+// countersCollector exports the control connection and OMP peer counters of the devices.
+type countersCollector struct {
+	*VmanageCollector
+}
+
+func (c *countersCollector) Run(ctx context.Context, devices map[string]vmanage.Device) error {
+	c.Logger.Infow("Refresh device counters")
+
+	res, err := c.Client.DeviceCounter(ctx)
+
+	if err != nil {
+		c.Logger.Warnw(
+			"Error fetching device counters",
+			"error", err,
+		)
+
+		c.ErrorCounter.Inc()
+		return err
+	}
+
+	// counters reference devices by system ip
+	counters := map[string]vmanage.DeviceCounter{}
+
+	for _, dc := range res {
+		counters[dc.SystemIP] = dc
+	}
+
+	c.Cache.Set("counters", counters, c.expiration("counters"))
+	return nil
+}
+
+func (c *countersCollector) Collect(devices map[string]vmanage.Device, ch chan<- prometheus.Metric) {
+	counters := map[string]vmanage.DeviceCounter{}
+
+	if dc, found := c.Cache.Get("counters"); found {
+		counters = dc.(map[string]vmanage.DeviceCounter)
+	}
+
+	for _, d := range devices {
+		deviceLabels := deviceLabels(d)
+
+		// control connection and omp peer counters
+		if dc, found := counters[d.SystemIP]; found {
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					"vmanage_device_control_connections",
+					"Number of control connections to vSmarts",
+					append(deviceLabels.Labels, "type"),
+					nil,
+				),
+				prometheus.GaugeValue,
+				float64(dc.ExpectedControlConnections),
+				append(deviceLabels.Values, "expected")...,
+			)
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					"vmanage_device_control_connections",
+					"Number of control connections to vSmarts",
+					append(deviceLabels.Labels, "type"),
+					nil,
+				),
+				prometheus.GaugeValue,
+				float64(dc.NumberVsmartControlConnections),
+				append(deviceLabels.Values, "actual")...,
+			)
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					"vmanage_device_omp_peers",
+					"Number of OMP peers",
+					append(deviceLabels.Labels, "state"),
+					nil,
+				),
+				prometheus.GaugeValue,
+				float64(dc.OmpPeersUp),
+				append(deviceLabels.Values, "up")...,
+			)
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					"vmanage_device_omp_peers",
+					"Number of OMP peers",
+					append(deviceLabels.Labels, "state"),
+					nil,
+				),
+				prometheus.GaugeValue,
+				float64(dc.OmpPeersDown),
+				append(deviceLabels.Values, "down")...,
+			)
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					"vmanage_device_reboots_total",
+					"Number of reboots of device",
+					deviceLabels.Labels,
+					nil,
+				),
+				prometheus.CounterValue,
+				float64(dc.RebootCount),
+				deviceLabels.Values...,
+			)
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					"vmanage_device_crashes_total",
+					"Number of crashes of device",
+					deviceLabels.Labels,
+					nil,
+				),
+				prometheus.CounterValue,
+				float64(dc.CrashCount),
+				deviceLabels.Values...,
+			)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+)
+
+func init() {
+	registerCollector("devices", true, func(c *VmanageCollector) SubCollector { return &devicesCollector{c} })
+}
+
+// devicesCollector exports the info, status and uptime of the devices in the device list.
+type devicesCollector struct {
+	*VmanageCollector
+}
+
+func (c *devicesCollector) Run(ctx context.Context, devices map[string]vmanage.Device) error {
+	// the device list is refreshed by VmanageCollector
+	return nil
+}
+
+func (c *devicesCollector) Collect(devices map[string]vmanage.Device, ch chan<- prometheus.Metric) {
+	// general stats
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc(
+			"vmanage_devices",
+			"Number of devices managed by vmanage",
+			[]string{},
+			nil,
+		),
+		prometheus.GaugeValue,
+		float64(len(devices)),
+	)
+
+	for _, d := range devices {
+		deviceLabels := deviceLabels(d)
+		infoLabels := deviceLabelsInfo(d, c.InfoLabels)
+
+		// device info
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				"vmanage_device_info",
+				"Info about device",
+				infoLabels.Labels,
+				nil,
+			),
+			prometheus.GaugeValue,
+			status(d.Status),
+			infoLabels.Values...,
+		)
+
+		// device stats
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				"vmanage_device_status",
+				"Status of device",
+				append(deviceLabels.Labels, "status"),
+				nil,
+			),
+			prometheus.GaugeValue,
+			status(d.Status),
+			append(deviceLabels.Values, d.Status)...,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				"vmanage_device_reachability",
+				"Reachability of device",
+				append(deviceLabels.Labels, "reachability"),
+				nil,
+			),
+			prometheus.GaugeValue,
+			boolValue(d.IsReachable()),
+			append(deviceLabels.Values, d.Reachability)...,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				"vmanage_device_uptime",
+				"Uptime of device",
+				deviceLabels.Labels,
+				nil,
+			),
+			prometheus.CounterValue,
+			uptime(d.UptimeDate),
+			deviceLabels.Values...,
+		)
+	}
+}
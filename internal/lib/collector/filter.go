@@ -0,0 +1,48 @@
+package collector
+
+import (
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+	"regexp"
+)
+
+// DeviceFilter selects the devices which are collected. Empty criteria match all devices.
+type DeviceFilter struct {
+	Hostname        *regexp.Regexp
+	ExcludeHostname *regexp.Regexp
+	SiteIDs         []string
+	DeviceTypes     []string
+}
+
+func (f *DeviceFilter) Match(d vmanage.Device) bool {
+	if f == nil {
+		return true
+	}
+
+	if f.Hostname != nil && !f.Hostname.MatchString(d.Hostname) {
+		return false
+	}
+
+	if f.ExcludeHostname != nil && f.ExcludeHostname.MatchString(d.Hostname) {
+		return false
+	}
+
+	if len(f.SiteIDs) > 0 && !contains(f.SiteIDs, d.SiteID) {
+		return false
+	}
+
+	if len(f.DeviceTypes) > 0 && !contains(f.DeviceTypes, d.DeviceType) {
+		return false
+	}
+
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+)
+
+func init() {
+	registerCollector("hardware", false, func(c *VmanageCollector) SubCollector { return &hardwareCollector{c} })
+}
+
+// hardwareCollector exports temperature, fan and power supply sensors of the devices.
+type hardwareCollector struct {
+	*VmanageCollector
+}
+
+func (c *hardwareCollector) Run(ctx context.Context, devices map[string]vmanage.Device) error {
+	if c.Bulk {
+		c.Logger.Infow("Refresh hardware environment in bulk")
+		return c.runBulk(ctx, devices)
+	}
+
+	return c.forEachDevice(ctx, devices, "hardware environment", func(deviceID string) error {
+		c.Logger.Infow("Refresh hardware environment", "DeviceID", deviceID)
+
+		res, err := c.Client.DeviceHardwareEnvironment(
+			ctx,
+			&vmanage.DeviceHardwareEnvironmentListOptions{DeviceID: deviceID},
+		)
+
+		if err != nil {
+			return err
+		}
+
+		c.Cache.Set(fmt.Sprintf("hardware_%s", deviceID), res, c.expiration("hardware"))
+		return nil
+	})
+}
+
+// runBulk fetches the hardware environment of all devices with a single paginated query.
+func (c *hardwareCollector) runBulk(ctx context.Context, devices map[string]vmanage.Device) error {
+	res, err := c.Client.DeviceStateHardwareEnvironment(ctx)
+
+	if err != nil {
+		c.Logger.Warnw(
+			"Error fetching hardware environment in bulk",
+			"error", err,
+		)
+
+		c.ErrorCounter.Inc()
+		return err
+	}
+
+	ids := deviceIDs(devices)
+	records := map[string][]vmanage.DeviceHardwareEnvironment{}
+
+	for _, r := range res {
+		if deviceID, ok := ids[r.VdeviceName]; ok {
+			records[deviceID] = append(records[deviceID], r)
+		}
+	}
+
+	for deviceID := range devices {
+		c.Cache.Set(fmt.Sprintf("hardware_%s", deviceID), records[deviceID], c.expiration("hardware"))
+	}
+
+	return nil
+}
+
+func (c *hardwareCollector) Collect(devices map[string]vmanage.Device, ch chan<- prometheus.Metric) {
+	for _, d := range devices {
+		// hardware environment
+		if hw, found := c.Cache.Get(fmt.Sprintf("hardware_%s", d.DeviceID)); found {
+			for _, e := range hw.([]vmanage.DeviceHardwareEnvironment) {
+				hwLabels := hardwareLabels(d, e)
+
+				switch {
+				case e.IsTemperature():
+					if t, ok := e.Temperature(); ok {
+						ch <- prometheus.MustNewConstMetric(
+							prometheus.NewDesc(
+								"vmanage_device_hardware_temperature_celsius",
+								"Temperature measured by sensor",
+								hwLabels.Labels,
+								nil,
+							),
+							prometheus.GaugeValue,
+							t,
+							hwLabels.Values...,
+						)
+					}
+
+				case e.IsFan():
+					ch <- prometheus.MustNewConstMetric(
+						prometheus.NewDesc(
+							"vmanage_device_hardware_fan_status",
+							"Status of fan (1 = OK)",
+							hwLabels.Labels,
+							nil,
+						),
+						prometheus.GaugeValue,
+						boolValue(e.IsOK()),
+						hwLabels.Values...,
+					)
+
+				case e.IsPowerSupply():
+					ch <- prometheus.MustNewConstMetric(
+						prometheus.NewDesc(
+							"vmanage_device_hardware_psu_status",
+							"Status of power supply (1 = OK)",
+							hwLabels.Labels,
+							nil,
+						),
+						prometheus.GaugeValue,
+						boolValue(e.IsOK()),
+						hwLabels.Values...,
+					)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+)
+
+func init() {
+	registerCollector("interfaces", true, func(c *VmanageCollector) SubCollector { return &interfacesCollector{c} })
+}
+
+// interfacesCollector exports the interface statistics of the devices.
+type interfacesCollector struct {
+	*VmanageCollector
+}
+
+func (c *interfacesCollector) Run(ctx context.Context, devices map[string]vmanage.Device) error {
+	if c.Bulk {
+		c.Logger.Infow("Refresh interface statistics in bulk")
+		return c.runBulk(ctx, devices)
+	}
+
+	return c.forEachDevice(ctx, devices, "interface statistics", func(deviceID string) error {
+		c.Logger.Infow("Refresh interface statistics", "DeviceID", deviceID)
+
+		res, err := c.Client.DeviceInterface(
+			ctx,
+			true,
+			&vmanage.DeviceInterfaceListOptions{DeviceID: deviceID},
+		)
+
+		if err != nil {
+			return err
+		}
+
+		c.Cache.Set(fmt.Sprintf("ifs_%s", deviceID), res, c.expiration("interfaces"))
+		return nil
+	})
+}
+
+// runBulk fetches the interface statistics of all devices with a single paginated query.
+func (c *interfacesCollector) runBulk(ctx context.Context, devices map[string]vmanage.Device) error {
+	res, err := c.Client.DeviceStateInterface(ctx)
+
+	if err != nil {
+		c.Logger.Warnw(
+			"Error fetching interface statistics in bulk",
+			"error", err,
+		)
+
+		c.ErrorCounter.Inc()
+		return err
+	}
+
+	ids := deviceIDs(devices)
+	records := map[string][]vmanage.DeviceInterface{}
+
+	for _, r := range res {
+		if deviceID, ok := ids[r.VdeviceName]; ok {
+			records[deviceID] = append(records[deviceID], r)
+		}
+	}
+
+	for deviceID := range devices {
+		c.Cache.Set(fmt.Sprintf("ifs_%s", deviceID), records[deviceID], c.expiration("interfaces"))
+	}
+
+	return nil
+}
+
+func (c *interfacesCollector) Collect(devices map[string]vmanage.Device, ch chan<- prometheus.Metric) {
+	for _, d := range devices {
+		// interface stats
+		if ifs, found := c.Cache.Get(fmt.Sprintf("ifs_%s", d.DeviceID)); found {
+			for _, i := range ifs.([]vmanage.DeviceInterface) {
+				ifLabels := interfaceLabels(d, i)
+
+				ch <- prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						"vmanage_device_interface_tx_octets",
+						"Interface TX Octets",
+						ifLabels.Labels,
+						nil,
+					),
+					prometheus.CounterValue,
+					float64(i.TxOctets),
+					ifLabels.Values...,
+				)
+
+				ch <- prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						"vmanage_device_interface_rx_octets",
+						"Interface RX Octets",
+						ifLabels.Labels,
+						nil,
+					),
+					prometheus.CounterValue,
+					float64(i.RxOctets),
+					ifLabels.Values...,
+				)
+
+				ch <- prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						"vmanage_device_interface_tx_packets",
+						"Interface TX Unicast Packets",
+						ifLabels.Labels,
+						nil,
+					),
+					prometheus.CounterValue,
+					float64(i.TxPackets),
+					ifLabels.Values...,
+				)
+
+				ch <- prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						"vmanage_device_interface_rx_packets",
+						"Interface RX Unicast Packets",
+						ifLabels.Labels,
+						nil,
+					),
+					prometheus.CounterValue,
+					float64(i.RxPackets),
+					ifLabels.Values...,
+				)
+
+				ch <- prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						"vmanage_device_interface_tx_errors",
+						"Interface Tx Errors",
+						ifLabels.Labels,
+						nil,
+					),
+					prometheus.CounterValue,
+					float64(i.TxErrors),
+					ifLabels.Values...,
+				)
+
+				ch <- prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						"vmanage_device_interface_rx_errors",
+						"Interface Rx Errors",
+						ifLabels.Labels,
+						nil,
+					),
+					prometheus.CounterValue,
+					float64(i.RxErrors),
+					ifLabels.Values...,
+				)
+
+				ch <- prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						"vmanage_device_interface_tx_drops",
+						"Interface Tx Drops",
+						ifLabels.Labels,
+						nil,
+					),
+					prometheus.CounterValue,
+					float64(i.TxDrops),
+					ifLabels.Values...,
+				)
+
+				ch <- prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						"vmanage_device_interface_rx_drops",
+						"Interface Rx Drops",
+						ifLabels.Labels,
+						nil,
+					),
+					prometheus.CounterValue,
+					float64(i.RxDrops),
+					ifLabels.Values...,
+				)
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"fmt"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+)
+
+// deviceInfoFields are the optional labels of vmanage_device_info.
+var deviceInfoFields = map[string]func(d vmanage.Device) string{
+	"SiteID":      func(d vmanage.Device) string { return d.SiteID },
+	"DeviceType":  func(d vmanage.Device) string { return d.DeviceType },
+	"Personality": func(d vmanage.Device) string { return d.Personality },
+	"Platform":    func(d vmanage.Device) string { return d.Platform },
+	"BoardSerial": func(d vmanage.Device) string { return d.BoardSerial },
+	"UUID":        func(d vmanage.Device) string { return d.UUID },
+	"Latitude":    func(d vmanage.Device) string { return d.Latitude },
+	"Longitude":   func(d vmanage.Device) string { return d.Longitude },
+	"Timezone":    func(d vmanage.Device) string { return d.Timezone },
+}
+
+func deviceLabelsInfo(d vmanage.Device, extra []string) struct {
+	Labels []string
+	Values []string
+} {
+	l := []string{"DeviceID", "SystemIP", "Hostname", "DeviceModel", "Version", "DeviceOS"}
+	v := []string{
+		d.DeviceID,
+		d.SystemIP,
+		d.Hostname,
+		d.DeviceModel,
+		d.Version,
+		d.DeviceOS,
+	}
+
+	for _, name := range extra {
+		if field, ok := deviceInfoFields[name]; ok {
+			l = append(l, name)
+			v = append(v, field(d))
+		}
+	}
+
+	return struct {
+		Labels []string
+		Values []string
+	}{
+		Labels: l,
+		Values: v,
+	}
+}
+
+func deviceLabels(d vmanage.Device) struct {
+	Labels []string
+	Values []string
+} {
+	l := []string{"DeviceID", "Hostname"}
+	v := []string{
+		d.DeviceID,
+		d.Hostname,
+	}
+
+	return struct {
+		Labels []string
+		Values []string
+	}{
+		Labels: l,
+		Values: v,
+	}
+}
+
+func interfaceLabels(d vmanage.Device, i vmanage.DeviceInterface) struct {
+	Labels []string
+	Values []string
+} {
+	l := []string{"DeviceID", "VdeviceName", "Ifname", "IfIndex", "AfType", "VdeviceDataKey"}
+	v := []string{
+		d.DeviceID,
+		i.VdeviceName,
+		i.Ifname,
+		fmt.Sprintf("%d", i.IfIndexInt()),
+		i.AfType,
+		i.VdeviceDataKey,
+	}
+
+	return struct {
+		Labels []string
+		Values []string
+	}{
+		Labels: l,
+		Values: v,
+	}
+}
+
+func bfdSessionLabels(d vmanage.Device, s vmanage.DeviceBFDSession) struct {
+	Labels []string
+	Values []string
+} {
+	// sessions between the same colors differ by address, e.g. IPv4 and IPv6 TLOCs
+	l := []string{"DeviceID", "Hostname", "LocalSystemIP", "RemoteSystemIP", "LocalColor", "RemoteColor", "Encapsulation", "SrcIP", "DstIP"}
+	v := []string{
+		d.DeviceID,
+		d.Hostname,
+		d.SystemIP,
+		s.SystemIP,
+		s.LocalColor,
+		s.Color,
+		s.Proto,
+		s.SrcIP,
+		s.DstIP,
+	}
+
+	return struct {
+		Labels []string
+		Values []string
+	}{
+		Labels: l,
+		Values: v,
+	}
+}
+
+func hardwareLabels(d vmanage.Device, e vmanage.DeviceHardwareEnvironment) struct {
+	Labels []string
+	Values []string
+} {
+	l := []string{"DeviceID", "Hostname", "hw_class", "hw_item", "hw_dev_index"}
+	v := []string{
+		d.DeviceID,
+		d.Hostname,
+		e.HwClass,
+		e.HwItem,
+		fmt.Sprintf("%d", e.HwDevIndex),
+	}
+
+	return struct {
+		Labels []string
+		Values []string
+	}{
+		Labels: l,
+		Values: v,
+	}
+}
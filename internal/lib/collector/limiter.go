@@ -0,0 +1,122 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+	"sync"
+	"time"
+)
+
+// Limiter limits the number of concurrent device requests to a vManage across all sub-collectors.
+// If adaptive, it adapts the limit to the load of vManage: it halves the limit when a request
+// is throttled or slower than SlowRequest and raises it by one after as many successful requests
+// as the current limit.
+type Limiter struct {
+	mu          sync.Mutex
+	max         int
+	slowRequest time.Duration
+	adaptive    bool
+	limit       int
+	successes   int
+	active      int
+	released    chan struct{}
+}
+
+func NewLimiter(max int, slowRequest time.Duration, adaptive bool) *Limiter {
+	return &Limiter{
+		max:         max,
+		slowRequest: slowRequest,
+		adaptive:    adaptive,
+		limit:       max,
+		released:    make(chan struct{}),
+	}
+}
+
+// Configure changes the maximum limit, the latency considered slow and whether the limit adapts.
+func (l *Limiter) Configure(max int, slowRequest time.Duration, adaptive bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.max = max
+	l.slowRequest = slowRequest
+	l.adaptive = adaptive
+
+	if l.limit > max || !adaptive {
+		l.limit = max
+	}
+}
+
+// Acquire waits until less requests than the current limit are active.
+func (l *Limiter) Acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+
+		if l.active < l.limit {
+			l.active++
+			l.mu.Unlock()
+			return nil
+		}
+
+		released := l.released
+		l.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Release ends a request acquired before and adapts the limit to its duration and error.
+func (l *Limiter) Release(duration time.Duration, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.active--
+
+	switch {
+	case !l.adaptive:
+	case errors.Is(err, vmanage.ErrThrottled) || (l.slowRequest > 0 && duration > l.slowRequest):
+		l.successes = 0
+
+		if l.limit /= 2; l.limit < 1 {
+			l.limit = 1
+		}
+	case err == nil:
+		if l.successes++; l.successes >= l.limit && l.limit < l.max {
+			l.successes = 0
+			l.limit++
+		}
+	}
+
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+// Limit returns the current number of concurrent requests allowed.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.limit
+}
+
+func (l *Limiter) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(l, ch)
+}
+
+func (l *Limiter) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc(
+			"vmanage_exporter_workers",
+			"Number of devices currently refreshed concurrently",
+			[]string{},
+			nil,
+		),
+		prometheus.GaugeValue,
+		float64(l.Limit()),
+	)
+}
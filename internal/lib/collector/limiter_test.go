@@ -0,0 +1,57 @@
+package collector_test
+
+import (
+	"context"
+	"github.com/zebbra/vmanage-exporter/internal/lib/collector"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+	"testing"
+	"time"
+)
+
+func TestLimiterShared(t *testing.T) {
+	l := collector.NewLimiter(2, 0, false)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := l.Acquire(ctx); err != nil {
+			t.Fatalf("Acquire failed: %s", err)
+		}
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	if err := l.Acquire(timeout); err == nil {
+		t.Fatal("Expected Acquire beyond the limit to block")
+	}
+
+	l.Release(time.Millisecond, vmanage.ErrThrottled)
+
+	if n := l.Limit(); n != 2 {
+		t.Errorf("Expected fixed limit of 2, got %d", n)
+	}
+
+	if err := l.Acquire(ctx); err != nil {
+		t.Fatalf("Acquire after release failed: %s", err)
+	}
+}
+
+func TestLimiterAdaptive(t *testing.T) {
+	l := collector.NewLimiter(4, time.Second, true)
+
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire failed: %s", err)
+	}
+
+	l.Release(time.Millisecond, vmanage.ErrThrottled)
+
+	if n := l.Limit(); n != 2 {
+		t.Errorf("Expected limit halved to 2, got %d", n)
+	}
+
+	l.Configure(4, time.Second, false)
+
+	if n := l.Limit(); n != 4 {
+		t.Errorf("Expected limit reset to 4 when not adaptive, got %d", n)
+	}
+}
@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+)
+
+// NodeCollector exports the state of the nodes of a vManage cluster.
+type NodeCollector struct {
+	Client *vmanage.Client
+}
+
+func (c *NodeCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *NodeCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, n := range c.Client.Nodes() {
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				"vmanage_exporter_node_active",
+				"Whether requests are sent to the vManage node",
+				[]string{"node"},
+				nil,
+			),
+			prometheus.GaugeValue,
+			boolValue(n.Active),
+			n.URL,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				"vmanage_exporter_node_up",
+				"Whether the last request or health check of the vManage node succeeded",
+				[]string{"node"},
+				nil,
+			),
+			prometheus.GaugeValue,
+			boolValue(n.Up),
+			n.URL,
+		)
+	}
+}
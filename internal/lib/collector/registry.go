@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+	"sort"
+)
+
+// SubCollector refreshes one kind of data of the devices into the cache and exposes it as metrics.
+type SubCollector interface {
+	Run(ctx context.Context, devices map[string]vmanage.Device) error
+	Collect(devices map[string]vmanage.Device, ch chan<- prometheus.Metric)
+}
+
+type factory func(c *VmanageCollector) SubCollector
+
+var (
+	factories = map[string]factory{}
+	defaults  = map[string]bool{}
+)
+
+// registerCollector makes a sub-collector available under name. It is called from init functions.
+func registerCollector(name string, enabledByDefault bool, f factory) {
+	factories[name] = f
+	defaults[name] = enabledByDefault
+}
+
+// Names returns the names of all sub-collectors in alphabetical order.
+func Names() []string {
+	names := make([]string, 0, len(factories))
+
+	for name := range factories {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// EnabledByDefault reports whether the named sub-collector runs unless disabled.
+func EnabledByDefault(name string) bool {
+	return defaults[name]
+}
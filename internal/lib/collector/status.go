@@ -0,0 +1,293 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status records the outcome of the last run of every sub-collector and of the last request
+// to every vManage API endpoint, so stale data can be detected. It outlives configuration reloads.
+type Status struct {
+	mu         sync.Mutex
+	collectors map[string]*result
+	endpoints  map[string]*result
+	skipped    map[string]int
+	retries    map[string]int
+	failovers  map[string]int
+	requests   *prometheus.HistogramVec
+
+	// window is the duration for which request outcomes are kept in buckets of a second
+	window  time.Duration
+	buckets []bucket
+}
+
+type bucket struct {
+	start    time.Time
+	requests int
+	errors   int
+}
+
+type result struct {
+	duration    time.Duration
+	success     bool
+	lastSuccess time.Time
+}
+
+func NewStatus() *Status {
+	return &Status{
+		collectors: map[string]*result{},
+		endpoints:  map[string]*result{},
+		skipped:    map[string]int{},
+		retries:    map[string]int{},
+		failovers:  map[string]int{},
+		requests: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "vmanage_exporter_api_request_duration_seconds",
+				Help:    "Duration of HTTP requests to the vManage API",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"method", "endpoint", "code"},
+		),
+	}
+}
+
+// ObserveCollector records a run of the named sub-collector. A nil Status ignores it.
+func (s *Status) ObserveCollector(name string, duration time.Duration, err error) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	observe(s.collectors, name, duration, err)
+}
+
+// ObserveSkipped records a scheduled run of the named collector which was skipped,
+// as the previous one was still running.
+func (s *Status) ObserveSkipped(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.skipped[name]++
+}
+
+// ObserveRetry records a request which is repeated after a retryable error.
+func (s *Status) ObserveRetry(endpoint string, err error) {
+	if i := strings.Index(endpoint, "?"); i >= 0 {
+		endpoint = endpoint[:i]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.retries[endpoint]++
+}
+
+// ObserveFailover records that requests switched to the vManage node at url.
+func (s *Status) ObserveFailover(url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failovers[url]++
+}
+
+// ObserveRequest records a request to an API endpoint. code is 0 if no response was received.
+func (s *Status) ObserveRequest(method string, endpoint string, code int, duration time.Duration, err error) {
+	if s == nil {
+		return
+	}
+
+	s.requests.WithLabelValues(method, endpoint, strconv.Itoa(code)).Observe(duration.Seconds())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	observe(s.endpoints, endpoint, duration, err)
+
+	now := time.Now().Truncate(time.Second)
+
+	if n := len(s.buckets); n == 0 || s.buckets[n-1].start.Before(now) {
+		s.buckets = append(s.buckets, bucket{start: now})
+	}
+
+	b := &s.buckets[len(s.buckets)-1]
+	b.requests++
+
+	if err != nil {
+		b.errors++
+	}
+
+	s.prune()
+}
+
+// SetWindow sets the duration within which Errors counts failed requests.
+func (s *Status) SetWindow(window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.window = window
+	s.prune()
+}
+
+func (s *Status) prune() {
+	i := 0
+
+	for i < len(s.buckets) && time.Since(s.buckets[i].start) > s.window {
+		i++
+	}
+
+	s.buckets = s.buckets[i:]
+}
+
+// Errors returns the number of failed and of all requests within the window.
+func (s *Status) Errors() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune()
+	errors, requests := 0, 0
+
+	for _, b := range s.buckets {
+		errors += b.errors
+		requests += b.requests
+	}
+
+	return errors, requests
+}
+
+// LastSuccess returns the time of the last successful run of the named collector,
+// which is zero if it never succeeded.
+func (s *Status) LastSuccess(collector string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r, ok := s.collectors[collector]; ok {
+		return r.lastSuccess
+	}
+
+	return time.Time{}
+}
+
+func observe(results map[string]*result, key string, duration time.Duration, err error) {
+	r, ok := results[key]
+
+	if !ok {
+		r = &result{}
+		results[key] = r
+	}
+
+	r.duration = duration
+	r.success = err == nil
+
+	if r.success {
+		r.lastSuccess = time.Now()
+	}
+}
+
+func (s *Status) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(s, ch)
+}
+
+// Collect exports the results with either the label collector or endpoint set.
+func (s *Status) Collect(ch chan<- prometheus.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, r := range s.collectors {
+		collectResult(ch, r, name, "")
+	}
+
+	for endpoint, r := range s.endpoints {
+		collectResult(ch, r, "", endpoint)
+	}
+
+	for name, n := range s.skipped {
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				"vmanage_exporter_collector_skipped_runs_total",
+				"Number of scheduled runs of a collector skipped as the previous run was still in progress",
+				[]string{"collector"},
+				nil,
+			),
+			prometheus.CounterValue,
+			float64(n),
+			name,
+		)
+	}
+
+	for endpoint, n := range s.retries {
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				"vmanage_exporter_api_retries_total",
+				"Number of requests to an API endpoint repeated after a retryable error",
+				[]string{"endpoint"},
+				nil,
+			),
+			prometheus.CounterValue,
+			float64(n),
+			endpoint,
+		)
+	}
+
+	for node, n := range s.failovers {
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				"vmanage_exporter_node_failovers_total",
+				"Number of times requests switched to a vManage node",
+				[]string{"node"},
+				nil,
+			),
+			prometheus.CounterValue,
+			float64(n),
+			node,
+		)
+	}
+
+	s.requests.Collect(ch)
+}
+
+func collectResult(ch chan<- prometheus.Metric, r *result, collector string, endpoint string) {
+	labels := []string{"collector", "endpoint"}
+
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc(
+			"vmanage_exporter_collector_duration_seconds",
+			"Duration of the last run of a collector or request to an API endpoint",
+			labels,
+			nil,
+		),
+		prometheus.GaugeValue,
+		r.duration.Seconds(),
+		collector, endpoint,
+	)
+
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc(
+			"vmanage_exporter_collector_success",
+			"Whether the last run of a collector or request to an API endpoint succeeded",
+			labels,
+			nil,
+		),
+		prometheus.GaugeValue,
+		boolValue(r.success),
+		collector, endpoint,
+	)
+
+	if !r.lastSuccess.IsZero() {
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				"vmanage_exporter_collector_last_success_timestamp_seconds",
+				"Time of the last successful run of a collector or request to an API endpoint",
+				labels,
+				nil,
+			),
+			prometheus.GaugeValue,
+			float64(r.lastSuccess.UnixNano())/1e9,
+			collector, endpoint,
+		)
+	}
+}
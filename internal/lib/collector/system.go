@@ -0,0 +1,191 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+)
+
+func init() {
+	registerCollector("system", true, func(c *VmanageCollector) SubCollector { return &systemCollector{c} })
+}
+
+// systemCollector exports memory, cpu and load statistics of the devices.
+type systemCollector struct {
+	*VmanageCollector
+}
+
+func (c *systemCollector) Run(ctx context.Context, devices map[string]vmanage.Device) error {
+	if c.Bulk {
+		c.Logger.Infow("Refresh system statistics in bulk")
+		return c.runBulk(ctx, devices)
+	}
+
+	return c.forEachDevice(ctx, devices, "system statistics", func(deviceID string) error {
+		c.Logger.Infow("Refresh system statistics", "DeviceID", deviceID)
+
+		res, err := c.Client.DeviceSystemStatus(
+			ctx,
+			false, // TODO: synced did not return valid data in test env?!
+			&vmanage.DeviceSystemStatusListOptions{DeviceID: deviceID},
+		)
+
+		if err != nil {
+			return err
+		}
+
+		if len(res) != 1 {
+			return fmt.Errorf("System statistics should return a single entry, got %d", len(res))
+		}
+
+		c.Cache.Set(fmt.Sprintf("system_status_%s", deviceID), res[0], c.expiration("system"))
+		return nil
+	})
+}
+
+// runBulk fetches the system statistics of all devices with a single paginated query.
+func (c *systemCollector) runBulk(ctx context.Context, devices map[string]vmanage.Device) error {
+	res, err := c.Client.DeviceStateSystemStatus(ctx)
+
+	if err != nil {
+		c.Logger.Warnw(
+			"Error fetching system statistics in bulk",
+			"error", err,
+		)
+
+		c.ErrorCounter.Inc()
+		return err
+	}
+
+	ids := deviceIDs(devices)
+
+	for _, ss := range res {
+		if deviceID, ok := ids[ss.VdeviceName]; ok {
+			c.Cache.Set(fmt.Sprintf("system_status_%s", deviceID), ss.SystemStatus(), c.expiration("system"))
+		}
+	}
+
+	return nil
+}
+
+func (c *systemCollector) Collect(devices map[string]vmanage.Device, ch chan<- prometheus.Metric) {
+	for _, d := range devices {
+		deviceLabels := deviceLabels(d)
+
+		// system stats
+		if ss, found := c.Cache.Get(fmt.Sprintf("system_status_%s", d.DeviceID)); found {
+			ss := ss.(vmanage.DeviceSystemStatus)
+			mem := ss.Memory()
+			cpu := ss.CPU()
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					"vmanage_device_mem_used",
+					"Memory Used",
+					deviceLabels.Labels,
+					nil,
+				),
+				prometheus.GaugeValue,
+				float64(mem.Used),
+				deviceLabels.Values...,
+			)
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					"vmanage_device_mem_free",
+					"Memory Free",
+					deviceLabels.Labels,
+					nil,
+				),
+				prometheus.GaugeValue,
+				float64(mem.Free),
+				deviceLabels.Values...,
+			)
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					"vmanage_device_mem_total",
+					"Memory Total",
+					deviceLabels.Labels,
+					nil,
+				),
+				prometheus.GaugeValue,
+				float64(mem.Total),
+				deviceLabels.Values...,
+			)
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					"vmanage_device_cpu_user_percentage",
+					"CPU User(%)",
+					deviceLabels.Labels,
+					nil,
+				),
+				prometheus.GaugeValue,
+				cpu.UserPercentage,
+				deviceLabels.Values...,
+			)
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					"vmanage_device_cpu_system_percentage",
+					"CPU System(%)",
+					deviceLabels.Labels,
+					nil,
+				),
+				prometheus.GaugeValue,
+				cpu.SystemPercentage,
+				deviceLabels.Values...,
+			)
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					"vmanage_device_cpu_idle_percentage",
+					"CPU Idle(%)",
+					deviceLabels.Labels,
+					nil,
+				),
+				prometheus.GaugeValue,
+				cpu.IdlePercentage,
+				deviceLabels.Values...,
+			)
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					"vmanage_device_load_avg1",
+					"Load Average 1 min",
+					deviceLabels.Labels,
+					nil,
+				),
+				prometheus.GaugeValue,
+				cpu.LoadAvg1,
+				deviceLabels.Values...,
+			)
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					"vmanage_device_load_avg5",
+					"Load Average 5 min",
+					deviceLabels.Labels,
+					nil,
+				),
+				prometheus.GaugeValue,
+				cpu.LoadAvg5,
+				deviceLabels.Values...,
+			)
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					"vmanage_device_load_avg15",
+					"Load Average 15 min",
+					deviceLabels.Labels,
+					nil,
+				),
+				prometheus.GaugeValue,
+				cpu.LoadAvg15,
+				deviceLabels.Values...,
+			)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package collector
+
+import (
+	"time"
+)
+
+func status(s string) float64 {
+	if s == "normal" {
+		return 1
+	}
+
+	return 0
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// uptime returns the milliseconds since ts.
+func uptime(ts int64) float64 {
+	return float64(time.Now().UnixMilli() - ts)
+}
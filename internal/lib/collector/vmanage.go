@@ -11,519 +11,271 @@ import (
 	"time"
 )
 
+// VmanageCollector refreshes the device list of a vManage instance and runs the enabled
+// sub-collectors, which refresh their data for these devices.
 type VmanageCollector struct {
 	Cache         *cache.Cache
 	Client        *vmanage.Client
 	Logger        *zap.SugaredLogger
 	ErrorCounter  *Counter
 	ScrapeCounter *Counter
+	// Status records the outcome of the device list refresh and the sub-collector runs, if set.
+	Status *Status
+
+	// Collectors are the names of the enabled sub-collectors.
+	Collectors []string
+	// Bulk fetches statistics of all devices at once instead of querying each device.
+	Bulk bool
+	// Workers is the number of devices refreshed concurrently.
+	Workers int
+	// Limiter limits the number of devices refreshed concurrently by all sub-collectors, if set.
+	// Otherwise every sub-collector refreshes up to Workers devices concurrently.
+	Limiter *Limiter
+	// InfoLabels are additional device attributes exported as labels of vmanage_device_info.
+	InfoLabels []string
+	// Filter selects the devices to collect.
+	Filter *DeviceFilter
+	// Intervals are the refresh intervals of the sub-collectors by name.
+	Intervals map[string]time.Duration
+	// AlarmLookback limits the alarms to those raised within this duration.
+	AlarmLookback time.Duration
+
+	once       sync.Once
+	collectors map[string]SubCollector
 }
 
+// Run refreshes the device list and the data of all enabled sub-collectors.
 func (c *VmanageCollector) Run(ctx context.Context) error {
-	c.Logger.Infow("Refresh device list")
 	startTime := time.Now()
 
-	devices := map[string]vmanage.Device{}
-	var queue chan string
-
-	if devs, err := c.Client.Device(ctx); err == nil {
-		queue = make(chan string, len(devs))
-
-		for _, d := range devs {
-			devices[d.DeviceID] = d
-			queue <- d.DeviceID
-		}
-
-		c.Logger.Infow("Successfully refreshed device list", "count", len(devices))
-		close(queue)
-
-	} else {
-		c.Logger.Errorw(
-			"Error fetching device list",
-			"error", err,
-		)
-
-		c.ErrorCounter.Inc()
+	if err := c.RefreshDevices(ctx); err != nil {
 		return err
 	}
 
-	synced := true
-
-	c.Cache.Set("devices", devices, cache.DefaultExpiration)
-
-	var wg sync.WaitGroup
-
-	worker := func() {
-		defer wg.Done()
-
-		for deviceID := range queue {
-			select {
-			case <-ctx.Done():
-				c.Logger.Warnw(
-					"Timed out refreshing interface statistics",
-					"error", ctx.Err(),
-				)
-				continue
-
-			default:
-				// fetch interface statistics
-				{
-					c.Logger.Infow("Refresh interface statistics", "DeviceID", deviceID)
-
-					res, err := c.Client.DeviceInterface(
-						ctx,
-						synced,
-						&vmanage.DeviceInterfaceListOptions{DeviceID: deviceID},
-					)
+	var errs []error
 
-					if err != nil {
-						c.Logger.Warnw(
-							"Error fetching interface statistics",
-							"DeviceID", deviceID,
-							"error", err,
-						)
-
-						c.ErrorCounter.Inc()
-					}
+	for _, name := range c.Collectors {
+		if err := c.RunCollector(ctx, name); err != nil {
+			errs = append(errs, err)
+		}
+	}
 
-					c.Cache.Set(fmt.Sprintf("ifs_%s", deviceID), res, cache.DefaultExpiration)
-				}
+	c.Logger.Infow(
+		"Refresh done",
+		"duration",
+		time.Now().Sub(startTime),
+	)
 
-				{
-					c.Logger.Infow("Refresh system statistics", "DeviceID", deviceID)
+	if len(errs) > 0 {
+		return fmt.Errorf("%d collectors failed, first error: %w", len(errs), errs[0])
+	}
 
-					res, err := c.Client.DeviceSystemStatus(
-						ctx,
-						false, // TODO: synced did not return valid data in test env?!
-						&vmanage.DeviceSystemStatusListOptions{DeviceID: deviceID},
-					)
+	return nil
+}
 
-					if err != nil {
-						c.Logger.Warnw(
-							"Error fetching system statistics",
-							"DeviceID", deviceID,
-							"error", err,
-						)
+// RefreshDevices fetches the device list the sub-collectors operate on.
+func (c *VmanageCollector) RefreshDevices(ctx context.Context) error {
+	startTime := time.Now()
+	err := c.refreshDevices(ctx)
+	c.Status.ObserveCollector("device_list", time.Since(startTime), err)
 
-						c.ErrorCounter.Inc()
+	return err
+}
 
-						continue
-					}
+func (c *VmanageCollector) refreshDevices(ctx context.Context) error {
+	c.Logger.Infow("Refresh device list")
 
-					if len(res) != 1 {
-						c.Logger.Warnw(
-							"Error fetching system statistics: should return a single entry",
-							"DeviceID", deviceID,
-							"data", res,
-						)
+	devs, err := c.Client.Device(ctx)
 
-						c.ErrorCounter.Inc()
+	if err != nil {
+		c.Logger.Errorw(
+			"Error fetching device list",
+			"error", err,
+		)
 
-						continue
-					}
+		c.ErrorCounter.Inc()
+		return err
+	}
 
-					c.Cache.Set(fmt.Sprintf("system_status_%s", deviceID), res[0], cache.DefaultExpiration)
-				}
+	devices := map[string]vmanage.Device{}
 
-			}
+	for _, d := range devs {
+		if c.Filter.Match(d) {
+			devices[d.DeviceID] = d
 		}
 	}
 
-	for i := 1; i <= 5; i++ {
-		wg.Add(1)
-		go worker()
-	}
-
-	wg.Wait()
-	c.Logger.Infow(
-		"Refresh done",
-		"duration",
-		time.Now().Sub(startTime),
-	)
+	c.Logger.Infow("Successfully refreshed device list", "count", len(devices), "filtered", len(devs)-len(devices))
+	c.Cache.Set("devices", devices, cache.DefaultExpiration)
 
 	return nil
 }
 
-func (c *VmanageCollector) Describe(ch chan<- *prometheus.Desc) {
-	prometheus.DescribeByCollect(c, ch)
+// RunCollector refreshes the data of the named sub-collector for the cached device list.
+func (c *VmanageCollector) RunCollector(ctx context.Context, name string) error {
+	startTime := time.Now()
+	err := c.runCollector(ctx, name)
+	c.Status.ObserveCollector(name, time.Since(startTime), err)
+
+	return err
 }
 
-func (c *VmanageCollector) Collect(ch chan<- prometheus.Metric) {
-	devices := map[string]vmanage.Device{}
+func (c *VmanageCollector) runCollector(ctx context.Context, name string) error {
+	sc, ok := c.subCollectors()[name]
 
-	if d, found := c.Cache.Get("devices"); found {
-		devices = d.(map[string]vmanage.Device)
-	} else {
-		return
+	if !ok {
+		return fmt.Errorf("Collector %s is not enabled", name)
 	}
 
-	// general stats
-	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(
-			"vmanage_devices",
-			"Number of devices managed by vmanage",
-			[]string{},
-			nil,
-		),
-		prometheus.GaugeValue,
-		float64(len(devices)),
-	)
+	devices, found := c.devices()
 
-	status := func(s string) float64 {
-		if s == "normal" {
-			return 1
-		}
+	if !found {
+		return fmt.Errorf("Collector %s: device list not available", name)
+	}
 
-		return 0
+	if err := sc.Run(ctx, devices); err != nil {
+		return fmt.Errorf("Collector %s: %w", name, err)
 	}
 
-	reachable := func(b bool) float64 {
-		if b {
-			return 1
+	return nil
+}
+
+func (c *VmanageCollector) subCollectors() map[string]SubCollector {
+	c.once.Do(func() {
+		c.collectors = map[string]SubCollector{}
+
+		for _, name := range c.Collectors {
+			if f, ok := factories[name]; ok {
+				c.collectors[name] = f(c)
+			}
 		}
+	})
 
-		return 0
-	}
+	return c.collectors
+}
 
-	uptime := func(ts int64) float64 {
-		return float64(time.Now().UnixMilli() - ts)
+// expiration returns how long the data of the named sub-collector is kept:
+// five refresh intervals like the device list, so it survives a few failed refreshes.
+func (c *VmanageCollector) expiration(name string) time.Duration {
+	if d := c.Intervals[name]; d > 0 {
+		return 5 * d
 	}
 
-	for _, d := range devices {
-		deviceLabels := deviceLabels(d)
-
-		// device info
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc(
-				"vmanage_device_info",
-				"Info about device",
-				deviceLabelsInfo(d).Labels,
-				nil,
-			),
-			prometheus.GaugeValue,
-			status(d.Status),
-			deviceLabelsInfo(d).Values...,
-		)
+	return cache.DefaultExpiration
+}
 
-		// device stats
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc(
-				"vmanage_device_status",
-				"Status of device",
-				append(deviceLabels.Labels, "status"),
-				nil,
-			),
-			prometheus.GaugeValue,
-			status(d.Status),
-			append(deviceLabels.Values, d.Status)...,
-		)
+func (c *VmanageCollector) devices() (map[string]vmanage.Device, bool) {
+	if d, found := c.Cache.Get("devices"); found {
+		return d.(map[string]vmanage.Device), true
+	}
 
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc(
-				"vmanage_device_reachability",
-				"Reachability of device",
-				append(deviceLabels.Labels, "reachability"),
-				nil,
-			),
-			prometheus.GaugeValue,
-			reachable(d.IsReachable()),
-			append(deviceLabels.Values, d.Reachability)...,
-		)
+	return nil, false
+}
 
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc(
-				"vmanage_device_uptime",
-				"Uptime of device",
-				deviceLabels.Labels,
-				nil,
-			),
-			prometheus.CounterValue,
-			uptime(d.UptimeDate),
-			deviceLabels.Values...,
-		)
+// forEachDevice calls fetch for every device with the configured number of workers.
+// It returns the first error returned by fetch.
+func (c *VmanageCollector) forEachDevice(ctx context.Context, devices map[string]vmanage.Device, what string, fetch func(deviceID string) error) error {
+	queue := make(chan string, len(devices))
 
-		// system stats
-		if ss, found := c.Cache.Get(fmt.Sprintf("system_status_%s", d.DeviceID)); found {
-			ss := ss.(vmanage.DeviceSystemStatus)
-			mem := ss.Memory()
-			cpu := ss.CPU()
-
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc(
-					"vmanage_device_mem_used",
-					"Memory Used",
-					deviceLabels.Labels,
-					nil,
-				),
-				prometheus.GaugeValue,
-				float64(mem.Used),
-				deviceLabels.Values...,
-			)
-
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc(
-					"vmanage_device_mem_free",
-					"Memory Free",
-					deviceLabels.Labels,
-					nil,
-				),
-				prometheus.GaugeValue,
-				float64(mem.Free),
-				deviceLabels.Values...,
-			)
-
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc(
-					"vmanage_device_mem_total",
-					"Memory Total",
-					deviceLabels.Labels,
-					nil,
-				),
-				prometheus.GaugeValue,
-				float64(mem.Total),
-				deviceLabels.Values...,
-			)
-
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc(
-					"vmanage_device_cpu_user_percentage",
-					"CPU User(%)",
-					deviceLabels.Labels,
-					nil,
-				),
-				prometheus.GaugeValue,
-				cpu.UserPercentage,
-				deviceLabels.Values...,
-			)
-
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc(
-					"vmanage_device_cpu_system_percentage",
-					"CPU System(%)",
-					deviceLabels.Labels,
-					nil,
-				),
-				prometheus.GaugeValue,
-				cpu.SystemPercentage,
-				deviceLabels.Values...,
-			)
-
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc(
-					"vmanage_device_cpu_idle_percentage",
-					"CPU Idle(%)",
-					deviceLabels.Labels,
-					nil,
-				),
-				prometheus.GaugeValue,
-				cpu.IdlePercentage,
-				deviceLabels.Values...,
-			)
-
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc(
-					"vmanage_device_load_avg1",
-					"Load Average 1 min",
-					deviceLabels.Labels,
-					nil,
-				),
-				prometheus.GaugeValue,
-				cpu.LoadAvg1,
-				deviceLabels.Values...,
-			)
-
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc(
-					"vmanage_device_load_avg5",
-					"Load Average 5 min",
-					deviceLabels.Labels,
-					nil,
-				),
-				prometheus.GaugeValue,
-				cpu.LoadAvg5,
-				deviceLabels.Values...,
-			)
-
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc(
-					"vmanage_device_load_avg15",
-					"Load Average 15 min",
-					deviceLabels.Labels,
-					nil,
-				),
-				prometheus.GaugeValue,
-				cpu.LoadAvg15,
-				deviceLabels.Values...,
-			)
-		}
+	for deviceID := range devices {
+		queue <- deviceID
+	}
 
-		// interface stats
-		if ifs, found := c.Cache.Get(fmt.Sprintf("ifs_%s", d.DeviceID)); found {
-			for _, i := range ifs.([]vmanage.DeviceInterface) {
-				ifLabels := interfaceLabels(d, i)
-
-				ch <- prometheus.MustNewConstMetric(
-					prometheus.NewDesc(
-						"vmanage_device_interface_tx_octets",
-						"Interface TX Octets",
-						ifLabels.Labels,
-						nil,
-					),
-					prometheus.CounterValue,
-					float64(i.TxOctets),
-					ifLabels.Values...,
-				)
+	close(queue)
 
-				ch <- prometheus.MustNewConstMetric(
-					prometheus.NewDesc(
-						"vmanage_device_interface_rx_octets",
-						"Interface RX Octets",
-						ifLabels.Labels,
-						nil,
-					),
-					prometheus.CounterValue,
-					float64(i.RxOctets),
-					ifLabels.Values...,
-				)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
 
-				ch <- prometheus.MustNewConstMetric(
-					prometheus.NewDesc(
-						"vmanage_device_interface_tx_packets",
-						"Interface TX Unicast Packets",
-						ifLabels.Labels,
-						nil,
-					),
-					prometheus.CounterValue,
-					float64(i.TxPackets),
-					ifLabels.Values...,
-				)
+	worker := func() {
+		defer wg.Done()
 
-				ch <- prometheus.MustNewConstMetric(
-					prometheus.NewDesc(
-						"vmanage_device_interface_rx_packets",
-						"Interface RX Unicast Packets",
-						ifLabels.Labels,
-						nil,
-					),
-					prometheus.CounterValue,
-					float64(i.RxPackets),
-					ifLabels.Values...,
+		for deviceID := range queue {
+			select {
+			case <-ctx.Done():
+				c.Logger.Warnw(
+					"Timed out refreshing "+what,
+					"error", ctx.Err(),
 				)
 
-				ch <- prometheus.MustNewConstMetric(
-					prometheus.NewDesc(
-						"vmanage_device_interface_tx_errors",
-						"Interface Tx Errors",
-						ifLabels.Labels,
-						nil,
-					),
-					prometheus.CounterValue,
-					float64(i.TxErrors),
-					ifLabels.Values...,
-				)
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = ctx.Err()
+				}
+				mu.Unlock()
+				continue
 
-				ch <- prometheus.MustNewConstMetric(
-					prometheus.NewDesc(
-						"vmanage_device_interface_rx_errors",
-						"Interface Rx Errors",
-						ifLabels.Labels,
-						nil,
-					),
-					prometheus.CounterValue,
-					float64(i.RxErrors),
-					ifLabels.Values...,
-				)
+			default:
+				if err := c.fetch(ctx, deviceID, fetch); err != nil {
+					c.Logger.Warnw(
+						"Error fetching "+what,
+						"DeviceID", deviceID,
+						"error", err,
+					)
 
-				ch <- prometheus.MustNewConstMetric(
-					prometheus.NewDesc(
-						"vmanage_device_interface_tx_drops",
-						"Interface Tx Drops",
-						ifLabels.Labels,
-						nil,
-					),
-					prometheus.CounterValue,
-					float64(i.TxDrops),
-					ifLabels.Values...,
-				)
+					c.ErrorCounter.Inc()
 
-				ch <- prometheus.MustNewConstMetric(
-					prometheus.NewDesc(
-						"vmanage_device_interface_rx_drops",
-						"Interface Rx Drops",
-						ifLabels.Labels,
-						nil,
-					),
-					prometheus.CounterValue,
-					float64(i.RxDrops),
-					ifLabels.Values...,
-				)
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
 			}
 		}
 	}
 
+	for i := 1; i <= c.Workers; i++ {
+		wg.Add(1)
+		go worker()
+	}
+
+	wg.Wait()
+	return firstErr
 }
 
-func deviceLabelsInfo(d vmanage.Device) struct {
-	Labels []string
-	Values []string
-} {
-	l := []string{"DeviceID", "SystemIP", "Hostname", "DeviceModel", "Version", "DeviceOS"}
-	v := []string{
-		d.DeviceID,
-		d.SystemIP,
-		d.Hostname,
-		d.DeviceModel,
-		d.Version,
-		d.DeviceOS,
+// fetch calls fetch for a device within the limit of concurrent requests.
+func (c *VmanageCollector) fetch(ctx context.Context, deviceID string, fetch func(deviceID string) error) error {
+	if c.Limiter == nil {
+		return fetch(deviceID)
 	}
 
-	return struct {
-		Labels []string
-		Values []string
-	}{
-		Labels: l,
-		Values: v,
+	if err := c.Limiter.Acquire(ctx); err != nil {
+		return err
 	}
+
+	startTime := time.Now()
+	err := fetch(deviceID)
+	c.Limiter.Release(time.Since(startTime), err)
+
+	return err
 }
 
-func deviceLabels(d vmanage.Device) struct {
-	Labels []string
-	Values []string
-} {
-	l := []string{"DeviceID", "Hostname"}
-	v := []string{
-		d.DeviceID,
-		d.Hostname,
-	}
+// deviceIDs maps the system ips, which the state and statistics APIs use to reference devices, to device ids.
+func deviceIDs(devices map[string]vmanage.Device) map[string]string {
+	ids := map[string]string{}
 
-	return struct {
-		Labels []string
-		Values []string
-	}{
-		Labels: l,
-		Values: v,
+	for _, d := range devices {
+		ids[d.SystemIP] = d.DeviceID
 	}
+
+	return ids
 }
 
-func interfaceLabels(d vmanage.Device, i vmanage.DeviceInterface) struct {
-	Labels []string
-	Values []string
-} {
-	l := []string{"DeviceID", "VdeviceName", "Ifname", "IfIndex", "AfType", "VdeviceDataKey"}
-	v := []string{
-		d.DeviceID,
-		i.VdeviceName,
-		i.Ifname,
-		fmt.Sprintf("%d", i.IfIndexInt()),
-		i.AfType,
-		i.VdeviceDataKey,
+func (c *VmanageCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *VmanageCollector) Collect(ch chan<- prometheus.Metric) {
+	devices, found := c.devices()
+
+	if !found {
+		return
 	}
 
-	return struct {
-		Labels []string
-		Values []string
-	}{
-		Labels: l,
-		Values: v,
+	for _, name := range c.Collectors {
+		if sc, ok := c.subCollectors()[name]; ok {
+			sc.Collect(devices, ch)
+		}
 	}
 }
@@ -0,0 +1,266 @@
+package collector_test
+
+import (
+	"context"
+	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/zebbra/vmanage-exporter/internal/lib/collector"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage/vmanagetest"
+	"go.uber.org/zap"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newCollector(t *testing.T, collectors ...string) (*collector.VmanageCollector, *vmanagetest.Server) {
+	t.Helper()
+
+	srv := vmanagetest.NewServer()
+	t.Cleanup(srv.Close)
+
+	client := vmanage.NewClient(srv.URL, vmanagetest.Username, vmanagetest.Password)
+	client.Retries = 0
+
+	errorCounter := collector.Counter(0)
+	scrapeCounter := collector.Counter(0)
+
+	c := &collector.VmanageCollector{
+		Cache:         cache.New(time.Minute, time.Minute),
+		Client:        client,
+		Logger:        zap.NewNop().Sugar(),
+		ErrorCounter:  &errorCounter,
+		ScrapeCounter: &scrapeCounter,
+		Status:        collector.NewStatus(),
+		Collectors:    collectors,
+		Workers:       2,
+		AlarmLookback: time.Hour,
+	}
+
+	return c, srv
+}
+
+func run(t *testing.T, c *collector.VmanageCollector) {
+	t.Helper()
+
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+}
+
+func compare(t *testing.T, c *collector.VmanageCollector, expected string, names ...string) {
+	t.Helper()
+
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), names...); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDevices(t *testing.T) {
+	c, _ := newCollector(t, "devices")
+	run(t, c)
+
+	compare(t, c, `
+# HELP vmanage_devices Number of devices managed by vmanage
+# TYPE vmanage_devices gauge
+vmanage_devices 2
+# HELP vmanage_device_status Status of device
+# TYPE vmanage_device_status gauge
+vmanage_device_status{DeviceID="10.0.0.1",Hostname="edge-zrh-1",status="normal"} 1
+vmanage_device_status{DeviceID="10.0.0.2",Hostname="edge-ber-1",status="error"} 0
+# HELP vmanage_device_reachability Reachability of device
+# TYPE vmanage_device_reachability gauge
+vmanage_device_reachability{DeviceID="10.0.0.1",Hostname="edge-zrh-1",reachability="reachable"} 1
+vmanage_device_reachability{DeviceID="10.0.0.2",Hostname="edge-ber-1",reachability="unreachable"} 0
+`, "vmanage_devices", "vmanage_device_status", "vmanage_device_reachability")
+}
+
+func TestSystem(t *testing.T) {
+	c, _ := newCollector(t, "system")
+	run(t, c)
+
+	compare(t, c, `
+# HELP vmanage_device_mem_used Memory Used
+# TYPE vmanage_device_mem_used gauge
+vmanage_device_mem_used{DeviceID="10.0.0.1",Hostname="edge-zrh-1"} 2048
+vmanage_device_mem_used{DeviceID="10.0.0.2",Hostname="edge-ber-1"} 4096
+# HELP vmanage_device_cpu_user_percentage CPU User(%)
+# TYPE vmanage_device_cpu_user_percentage gauge
+vmanage_device_cpu_user_percentage{DeviceID="10.0.0.1",Hostname="edge-zrh-1"} 10.5
+vmanage_device_cpu_user_percentage{DeviceID="10.0.0.2",Hostname="edge-ber-1"} 50
+`, "vmanage_device_mem_used", "vmanage_device_cpu_user_percentage")
+}
+
+func TestInterfaces(t *testing.T) {
+	c, _ := newCollector(t, "interfaces")
+	run(t, c)
+
+	compare(t, c, `
+# HELP vmanage_device_interface_rx_octets Interface RX Octets
+# TYPE vmanage_device_interface_rx_octets counter
+vmanage_device_interface_rx_octets{AfType="ipv4",DeviceID="10.0.0.1",IfIndex="1",Ifname="GigabitEthernet1",VdeviceDataKey="",VdeviceName="10.0.0.1"} 1000
+vmanage_device_interface_rx_octets{AfType="ipv4",DeviceID="10.0.0.1",IfIndex="2",Ifname="GigabitEthernet2",VdeviceDataKey="",VdeviceName="10.0.0.1"} 0
+vmanage_device_interface_rx_octets{AfType="ipv4",DeviceID="10.0.0.2",IfIndex="1",Ifname="GigabitEthernet1",VdeviceDataKey="",VdeviceName="10.0.0.2"} 5000
+`, "vmanage_device_interface_rx_octets")
+}
+
+func TestBulk(t *testing.T) {
+	c, srv := newCollector(t, "system", "interfaces")
+	c.Bulk = true
+	run(t, c)
+
+	if n := srv.Requests("/dataservice/device/system/status"); n != 0 {
+		t.Errorf("Expected no per device requests in bulk mode, got %d", n)
+	}
+
+	compare(t, c, `
+# HELP vmanage_device_interface_tx_octets Interface TX Octets
+# TYPE vmanage_device_interface_tx_octets counter
+vmanage_device_interface_tx_octets{AfType="ipv4",DeviceID="10.0.0.1",IfIndex="1",Ifname="GigabitEthernet1",VdeviceDataKey="",VdeviceName="10.0.0.1"} 2000
+vmanage_device_interface_tx_octets{AfType="ipv4",DeviceID="10.0.0.1",IfIndex="2",Ifname="GigabitEthernet2",VdeviceDataKey="",VdeviceName="10.0.0.1"} 0
+vmanage_device_interface_tx_octets{AfType="ipv4",DeviceID="10.0.0.2",IfIndex="1",Ifname="GigabitEthernet1",VdeviceDataKey="",VdeviceName="10.0.0.2"} 6000
+# HELP vmanage_device_mem_free Memory Free
+# TYPE vmanage_device_mem_free gauge
+vmanage_device_mem_free{DeviceID="10.0.0.1",Hostname="edge-zrh-1"} 6144
+vmanage_device_mem_free{DeviceID="10.0.0.2",Hostname="edge-ber-1"} 4096
+`, "vmanage_device_interface_tx_octets", "vmanage_device_mem_free")
+}
+
+func TestBFDSessions(t *testing.T) {
+	c, srv := newCollector(t, "bfd")
+
+	// IPv4 and IPv6 sessions between the same colors
+	srv.Respond("/dataservice/device/bfd/sessions", `{"data": [
+		{"vdevice-name": "10.0.0.1", "system-ip": "10.0.0.2", "state": "up", "src-ip": "192.0.2.1", "dst-ip": "192.0.2.2", "local-color": "biz-internet", "color": "biz-internet", "proto": "ipsec"},
+		{"vdevice-name": "10.0.0.1", "system-ip": "10.0.0.2", "state": "down", "src-ip": "2001:db8::1", "dst-ip": "2001:db8::2", "local-color": "biz-internet", "color": "biz-internet", "proto": "ipsec"}
+	]}`)
+
+	run(t, c)
+
+	compare(t, c, `
+# HELP vmanage_bfd_session_state State of BFD session (1 = up)
+# TYPE vmanage_bfd_session_state gauge
+vmanage_bfd_session_state{DeviceID="10.0.0.1",DstIP="192.0.2.2",Encapsulation="ipsec",Hostname="edge-zrh-1",LocalColor="biz-internet",LocalSystemIP="10.0.0.1",RemoteColor="biz-internet",RemoteSystemIP="10.0.0.2",SrcIP="192.0.2.1"} 1
+vmanage_bfd_session_state{DeviceID="10.0.0.1",DstIP="2001:db8::2",Encapsulation="ipsec",Hostname="edge-zrh-1",LocalColor="biz-internet",LocalSystemIP="10.0.0.1",RemoteColor="biz-internet",RemoteSystemIP="10.0.0.2",SrcIP="2001:db8::1"} 0
+`, "vmanage_bfd_session_state")
+}
+
+func TestAppRoute(t *testing.T) {
+	c, srv := newCollector(t, "app_route")
+
+	// two buckets of an IPv4 tunnel, the second one newer, and an IPv6 tunnel between the same colors
+	srv.Respond("/dataservice/device/app-route/statistics", `{"data": [
+		{"vdevice-name": "10.0.0.1", "remote-system-ip": "10.0.0.2", "src-ip": "192.0.2.1", "dst-ip": "192.0.2.2", "local-color": "mpls", "remote-color": "mpls", "proto": "ipsec", "sla-class-index": "0", "index": 0, "mean-latency": 12, "tx-pkts": 100, "rx-pkts": 98, "lastupdated": 1650000000000},
+		{"vdevice-name": "10.0.0.1", "remote-system-ip": "10.0.0.2", "src-ip": "192.0.2.1", "dst-ip": "192.0.2.2", "local-color": "mpls", "remote-color": "mpls", "proto": "ipsec", "sla-class-index": "0", "index": 1, "mean-latency": 14, "tx-pkts": 50, "rx-pkts": 49, "lastupdated": 1650000600000},
+		{"vdevice-name": "10.0.0.1", "remote-system-ip": "10.0.0.2", "src-ip": "2001:db8::1", "dst-ip": "2001:db8::2", "local-color": "mpls", "remote-color": "mpls", "proto": "ipsec", "sla-class-index": "0", "index": 0, "mean-latency": 20, "tx-pkts": 10, "rx-pkts": 10, "lastupdated": 1650000000000}
+	]}`)
+
+	run(t, c)
+
+	compare(t, c, `
+# HELP vmanage_tunnel_latency_ms Mean latency of tunnel
+# TYPE vmanage_tunnel_latency_ms gauge
+vmanage_tunnel_latency_ms{DeviceID="10.0.0.1",DstIP="192.0.2.2",Encapsulation="ipsec",Hostname="edge-zrh-1",LocalColor="mpls",LocalSystemIP="10.0.0.1",RemoteColor="mpls",RemoteSystemIP="10.0.0.2",SLAClass="0",SrcIP="192.0.2.1"} 14
+vmanage_tunnel_latency_ms{DeviceID="10.0.0.1",DstIP="2001:db8::2",Encapsulation="ipsec",Hostname="edge-zrh-1",LocalColor="mpls",LocalSystemIP="10.0.0.1",RemoteColor="mpls",RemoteSystemIP="10.0.0.2",SLAClass="0",SrcIP="2001:db8::1"} 20
+# HELP vmanage_tunnel_tx_packets Packets sent through tunnel within the measurement interval
+# TYPE vmanage_tunnel_tx_packets gauge
+vmanage_tunnel_tx_packets{DeviceID="10.0.0.1",DstIP="192.0.2.2",Encapsulation="ipsec",Hostname="edge-zrh-1",LocalColor="mpls",LocalSystemIP="10.0.0.1",RemoteColor="mpls",RemoteSystemIP="10.0.0.2",SLAClass="0",SrcIP="192.0.2.1"} 150
+vmanage_tunnel_tx_packets{DeviceID="10.0.0.1",DstIP="2001:db8::2",Encapsulation="ipsec",Hostname="edge-zrh-1",LocalColor="mpls",LocalSystemIP="10.0.0.1",RemoteColor="mpls",RemoteSystemIP="10.0.0.2",SLAClass="0",SrcIP="2001:db8::1"} 10
+`, "vmanage_tunnel_latency_ms", "vmanage_tunnel_tx_packets")
+}
+
+func TestCounters(t *testing.T) {
+	c, _ := newCollector(t, "counters")
+	run(t, c)
+
+	compare(t, c, `
+# HELP vmanage_device_control_connections Number of control connections to vSmarts
+# TYPE vmanage_device_control_connections gauge
+vmanage_device_control_connections{DeviceID="10.0.0.1",Hostname="edge-zrh-1",type="actual"} 2
+vmanage_device_control_connections{DeviceID="10.0.0.1",Hostname="edge-zrh-1",type="expected"} 2
+vmanage_device_control_connections{DeviceID="10.0.0.2",Hostname="edge-ber-1",type="actual"} 0
+vmanage_device_control_connections{DeviceID="10.0.0.2",Hostname="edge-ber-1",type="expected"} 2
+`, "vmanage_device_control_connections")
+}
+
+func TestAlarms(t *testing.T) {
+	c, _ := newCollector(t, "alarms")
+	run(t, c)
+
+	compare(t, c, `
+# HELP vmanage_alarms_active Number of active alarms by severity
+# TYPE vmanage_alarms_active gauge
+vmanage_alarms_active{severity="Critical"} 2
+`, "vmanage_alarms_active")
+}
+
+func TestAllCollectors(t *testing.T) {
+	c, _ := newCollector(t, collector.Names()...)
+	run(t, c)
+
+	if n := testutil.CollectAndCount(c); n == 0 {
+		t.Error("Expected metrics of all collectors")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	c, _ := newCollector(t, "devices")
+	c.Filter = &collector.DeviceFilter{Hostname: regexp.MustCompile("-zrh-")}
+	run(t, c)
+
+	compare(t, c, `
+# HELP vmanage_devices Number of devices managed by vmanage
+# TYPE vmanage_devices gauge
+vmanage_devices 1
+`, "vmanage_devices")
+}
+
+func TestCollectorFailure(t *testing.T) {
+	c, srv := newCollector(t, "devices", "system")
+	run(t, c)
+
+	last := c.Status.LastSuccess("system")
+
+	if last.IsZero() {
+		t.Fatal("Expected system collector to succeed")
+	}
+
+	// the system status of both devices fails
+	srv.Fail("/dataservice/device/system/status", http.StatusInternalServerError, http.StatusInternalServerError)
+
+	if err := c.Run(context.Background()); err == nil {
+		t.Fatal("Expected Run to fail")
+	}
+
+	if l := c.Status.LastSuccess("system"); !l.Equal(last) {
+		t.Errorf("Expected last success %s of the system collector to be kept, got %s", last, l)
+	}
+
+	if n := c.ErrorCounter.Get(); n != 2 {
+		t.Errorf("Expected 2 errors, got %d", n)
+	}
+
+	// the values of the previous run are still exported
+	compare(t, c, `
+# HELP vmanage_device_mem_used Memory Used
+# TYPE vmanage_device_mem_used gauge
+vmanage_device_mem_used{DeviceID="10.0.0.1",Hostname="edge-zrh-1"} 2048
+vmanage_device_mem_used{DeviceID="10.0.0.2",Hostname="edge-ber-1"} 4096
+`, "vmanage_device_mem_used")
+}
+
+func TestEnabledByDefault(t *testing.T) {
+	var enabled []string
+
+	for _, name := range collector.Names() {
+		if collector.EnabledByDefault(name) {
+			enabled = append(enabled, name)
+		}
+	}
+
+	if strings.Join(enabled, ",") != "devices,interfaces,system" {
+		t.Errorf("Expected only devices, interfaces and system to be enabled by default, got %v", enabled)
+	}
+}
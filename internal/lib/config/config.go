@@ -0,0 +1,395 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zebbra/vmanage-exporter/internal/lib/collector"
+	"gopkg.in/yaml.v2"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of the exporter. Values missing in the configuration file
+// keep the defaults passed to Load, which are taken from the command line flags.
+//
+// Without a list of targets a single vManage is collected as configured at the top level.
+// Otherwise the top level settings serve as defaults of every target.
+type Config struct {
+	Target  `yaml:",inline"`
+	Targets []Target `yaml:"targets"`
+}
+
+// Target is a vManage instance and the settings used to collect it.
+type Target struct {
+	Name       string     `yaml:"name"`
+	VManage    VManage    `yaml:"vmanage"`
+	Scrape     Scrape     `yaml:"scrape"`
+	Collectors Collectors `yaml:"collectors"`
+	Labels     Labels     `yaml:"labels"`
+	Filters    Filters    `yaml:"filters"`
+}
+
+type VManage struct {
+	// Endpoint is the preferred node of a vManage cluster, Endpoints are its further nodes.
+	Endpoint    string        `yaml:"endpoint"`
+	Endpoints   []string      `yaml:"endpoints"`
+	Credentials Credentials   `yaml:"credentials"`
+	TLSVerify   bool          `yaml:"tls_verify"`
+	Timeout     time.Duration `yaml:"timeout"`
+	PoolSize    int           `yaml:"pool_size"`
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+	PageSize    int           `yaml:"page_size"`
+	// RateLimit is the maximum number of requests per second, 0 disables the limit.
+	RateLimit float64 `yaml:"rate_limit"`
+	RateBurst int     `yaml:"rate_burst"`
+	// Retries is the number of times requests failing while vManage is unavailable are repeated.
+	Retries      int           `yaml:"retries"`
+	RetryWait    time.Duration `yaml:"retry_wait"`
+	RetryMaxWait time.Duration `yaml:"retry_max_wait"`
+	// BreakerThreshold is the number of consecutive failed requests which stop requests for BreakerTimeout.
+	// 0 disables the circuit breaker.
+	BreakerThreshold int           `yaml:"breaker_threshold"`
+	BreakerTimeout   time.Duration `yaml:"breaker_timeout"`
+	// Auth selects the login method: session, jwt or auto, which uses jwt if vManage supports it.
+	Auth string `yaml:"auth"`
+	// JWTDuration is the requested lifetime of JWTs.
+	JWTDuration time.Duration `yaml:"jwt_duration"`
+	// Tenants selects the tenants of a multi-tenant vManage to collect by name or id, "*" selects all.
+	// Requires a login as provider. Every metric is labelled with the tenant.
+	Tenants []string `yaml:"tenants"`
+	// HealthInterval is the interval at which preferred nodes are checked after a failover.
+	HealthInterval time.Duration `yaml:"health_interval"`
+	// RecordDir stores the responses of vManage, with secrets redacted, in a subdirectory per target.
+	RecordDir string `yaml:"record_dir"`
+	// ReplayDir serves the responses recorded in RecordDir instead of requesting vManage.
+	ReplayDir string `yaml:"replay_dir"`
+}
+
+// Credentials reference the environment variables or files holding the vManage login.
+// Files take precedence over environment variables.
+type Credentials struct {
+	UsernameEnv  string `yaml:"username_env"`
+	PasswordEnv  string `yaml:"password_env"`
+	UsernameFile string `yaml:"username_file"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+type Scrape struct {
+	Interval time.Duration `yaml:"interval"`
+	Workers  int           `yaml:"workers"`
+	// AdaptiveWorkers reduces the number of workers while vManage throttles or responds slower
+	// than SlowRequest and raises it up to Workers again when it recovers.
+	AdaptiveWorkers bool          `yaml:"adaptive_workers"`
+	SlowRequest     time.Duration `yaml:"slow_request"`
+	Bulk            bool          `yaml:"bulk"`
+	// MaxErrorRatio is the fraction of requests failed within ErrorWindow above which the target is not ready.
+	MaxErrorRatio float64 `yaml:"max_error_ratio"`
+	// MaxErrors is the number of failed requests within ErrorWindow above which the target is not ready,
+	// 0 disables the check. Deprecated, kept for existing configurations: use MaxErrorRatio.
+	MaxErrors   int           `yaml:"max_errors"`
+	ErrorWindow time.Duration `yaml:"error_window"`
+	// StaleIntervals is the number of scrape intervals without successful refresh of the device list
+	// after which the target is not ready.
+	StaleIntervals int `yaml:"stale_intervals"`
+	// ProbeOnly disables the periodic refresh, the target is only collected through /probe.
+	ProbeOnly bool `yaml:"probe_only"`
+}
+
+type Collectors struct {
+	// Enabled are the names of the sub-collectors to run.
+	Enabled []string `yaml:"enabled"`
+	// Intervals override the refresh interval of single sub-collectors, which defaults to scrape.interval.
+	Intervals      map[string]time.Duration `yaml:"intervals"`
+	AlarmsLookback time.Duration            `yaml:"alarms_lookback"`
+}
+
+type Labels struct {
+	// DeviceInfo lists additional device attributes exported as labels of vmanage_device_info.
+	DeviceInfo []string `yaml:"device_info"`
+}
+
+// AuthMethods are the valid values of VManage.Auth.
+var AuthMethods = []string{"auto", "session", "jwt"}
+
+// DeviceInfoLabels are the device attributes which can be added to vmanage_device_info.
+var DeviceInfoLabels = []string{
+	"SiteID",
+	"DeviceType",
+	"Personality",
+	"Platform",
+	"BoardSerial",
+	"UUID",
+	"Latitude",
+	"Longitude",
+	"Timezone",
+}
+
+type Filters struct {
+	Hostname        string   `yaml:"hostname"`
+	ExcludeHostname string   `yaml:"exclude_hostname"`
+	SiteIDs         []string `yaml:"site_ids"`
+	DeviceTypes     []string `yaml:"device_types"`
+}
+
+// Load reads the configuration file at path on top of defaults.
+func Load(path string, defaults Config) (*Config, error) {
+	b, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := defaults
+
+	if err := yaml.UnmarshalStrict(b, &cfg); err != nil {
+		return nil, fmt.Errorf("Error parsing %s: %w", path, err)
+	}
+
+	// parse targets again on top of the top level settings
+	var raw struct {
+		Targets []interface{} `yaml:"targets"`
+	}
+
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("Error parsing %s: %w", path, err)
+	}
+
+	for i, t := range raw.Targets {
+		tb, err := yaml.Marshal(t)
+
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.Targets[i] = cfg.Target
+		cfg.Targets[i].Name = ""
+		cfg.Targets[i].Collectors.Intervals = map[string]time.Duration{}
+
+		// copy the map, the target would otherwise add its intervals to the top level ones
+		for name, d := range cfg.Collectors.Intervals {
+			cfg.Targets[i].Collectors.Intervals[name] = d
+		}
+
+		if err := yaml.UnmarshalStrict(tb, &cfg.Targets[i]); err != nil {
+			return nil, fmt.Errorf("Error parsing target %d in %s: %w", i+1, path, err)
+		}
+	}
+
+	return &cfg, nil
+}
+
+// TargetList returns the vManage instances to collect. If no name is configured
+// for a single instance, the host name of its endpoint is used.
+func (c *Config) TargetList() []Target {
+	if len(c.Targets) > 0 {
+		return c.Targets
+	}
+
+	t := c.Target
+
+	if t.Name == "" {
+		if u, err := url.Parse(t.VManage.Endpoint); err == nil {
+			t.Name = u.Hostname()
+		}
+	}
+
+	return []Target{t}
+}
+
+// Validate checks the configuration for errors which would only show up while collecting.
+func (c *Config) Validate() error {
+	var errs []string
+	names := map[string]bool{}
+
+	for i, t := range c.TargetList() {
+		if t.Name == "" {
+			errs = append(errs, fmt.Sprintf("target %d: name is required", i+1))
+		} else if names[t.Name] {
+			errs = append(errs, fmt.Sprintf("target %s: name is not unique", t.Name))
+		}
+
+		names[t.Name] = true
+
+		if err := t.Validate(); err != nil {
+			errs = append(errs, fmt.Sprintf("target %s: %s", t.Name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New("Invalid configuration: " + strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// Validate checks the settings of a single target.
+func (c *Target) Validate() error {
+	var errs []string
+
+	if c.VManage.Endpoint == "" {
+		errs = append(errs, "vmanage.endpoint is required")
+	} else if u, err := url.Parse(c.VManage.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Sprintf("vmanage.endpoint %q is not a valid URL", c.VManage.Endpoint))
+	}
+
+	for _, e := range c.VManage.Endpoints {
+		if u, err := url.Parse(e); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Sprintf("vmanage.endpoints: %q is not a valid URL", e))
+		}
+	}
+
+	if len(c.VManage.Endpoints) > 0 && c.VManage.HealthInterval <= 0 {
+		errs = append(errs, "vmanage.health_interval must be positive")
+	}
+
+	// replayed responses do not need credentials
+	if c.VManage.ReplayDir == "" {
+		if _, _, err := c.VManage.Credentials.Resolve(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if !contains(AuthMethods, c.VManage.Auth) {
+		errs = append(errs, fmt.Sprintf("vmanage.auth: unknown method %q, valid methods are %s", c.VManage.Auth, strings.Join(AuthMethods, ", ")))
+	}
+
+	if c.VManage.RecordDir != "" && c.VManage.ReplayDir != "" {
+		errs = append(errs, "vmanage.record_dir and vmanage.replay_dir are mutually exclusive")
+	}
+
+	if c.Scrape.Interval <= 0 {
+		errs = append(errs, "scrape.interval must be positive")
+	}
+
+	if c.Scrape.Workers <= 0 {
+		errs = append(errs, "scrape.workers must be positive")
+	}
+
+	if c.VManage.RateLimit < 0 {
+		errs = append(errs, "vmanage.rate_limit must not be negative")
+	}
+
+	if c.VManage.RateLimit > 0 && c.VManage.RateBurst <= 0 {
+		errs = append(errs, "vmanage.rate_burst must be positive")
+	}
+
+	if c.VManage.Retries < 0 {
+		errs = append(errs, "vmanage.retries must not be negative")
+	}
+
+	if c.VManage.RetryWait <= 0 || c.VManage.RetryMaxWait < c.VManage.RetryWait {
+		errs = append(errs, "vmanage.retry_wait must be positive and not exceed vmanage.retry_max_wait")
+	}
+
+	if c.VManage.BreakerThreshold < 0 {
+		errs = append(errs, "vmanage.breaker_threshold must not be negative")
+	}
+
+	if c.Scrape.MaxErrorRatio <= 0 || c.Scrape.MaxErrorRatio > 1 {
+		errs = append(errs, "scrape.max_error_ratio must be greater than 0 and at most 1")
+	}
+
+	if c.Scrape.MaxErrors < 0 {
+		errs = append(errs, "scrape.max_errors must not be negative")
+	}
+
+	if c.Scrape.ErrorWindow <= 0 {
+		errs = append(errs, "scrape.error_window must be positive")
+	}
+
+	if c.Scrape.StaleIntervals <= 0 {
+		errs = append(errs, "scrape.stale_intervals must be positive")
+	}
+
+	for _, name := range c.Collectors.Enabled {
+		if !contains(collector.Names(), name) {
+			errs = append(errs, fmt.Sprintf("collectors.enabled: unknown collector %q, valid collectors are %s", name, strings.Join(collector.Names(), ", ")))
+		}
+	}
+
+	for name, d := range c.Collectors.Intervals {
+		if !contains(collector.Names(), name) {
+			errs = append(errs, fmt.Sprintf("collectors.intervals: unknown collector %q", name))
+		} else if d < 0 {
+			errs = append(errs, fmt.Sprintf("collectors.intervals: interval of %s must not be negative", name))
+		}
+	}
+
+	for _, l := range c.Labels.DeviceInfo {
+		if !contains(DeviceInfoLabels, l) {
+			errs = append(errs, fmt.Sprintf("labels.device_info: unknown label %q, valid labels are %s", l, strings.Join(DeviceInfoLabels, ", ")))
+		}
+	}
+
+	for name, expr := range map[string]string{
+		"filters.hostname":         c.Filters.Hostname,
+		"filters.exclude_hostname": c.Filters.ExcludeHostname,
+	} {
+		if _, err := regexp.Compile(expr); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// Interval returns the refresh interval of the named sub-collector.
+func (c *Target) Interval(name string) time.Duration {
+	if d := c.Collectors.Intervals[name]; d > 0 {
+		return d
+	}
+
+	return c.Scrape.Interval
+}
+
+// Resolve returns the username and password referenced by the credentials.
+func (c Credentials) Resolve() (string, string, error) {
+	username, err := resolve("username", c.UsernameFile, c.UsernameEnv)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	password, err := resolve("password", c.PasswordFile, c.PasswordEnv)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	return username, password, nil
+}
+
+func resolve(name string, file string, env string) (string, error) {
+	if file != "" {
+		b, err := os.ReadFile(file)
+
+		if err != nil {
+			return "", fmt.Errorf("Error reading vmanage %s: %w", name, err)
+		}
+
+		return strings.TrimSpace(string(b)), nil
+	}
+
+	if v := os.Getenv(env); v != "" {
+		return v, nil
+	}
+
+	return "", fmt.Errorf("Please provide vmanage %s in environment variable %s", name, env)
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
+}
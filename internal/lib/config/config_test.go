@@ -0,0 +1,169 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func defaults() Config {
+	return Config{
+		Target: Target{
+			VManage: VManage{
+				Endpoint:     "https://vmanage.example.com",
+				Auth:         "auto",
+				Credentials:  Credentials{UsernameEnv: "TEST_VMANAGE_USER", PasswordEnv: "TEST_VMANAGE_PASSWORD"},
+				RetryWait:    time.Second,
+				RetryMaxWait: 10 * time.Second,
+			},
+			Scrape: Scrape{
+				Interval:       30 * time.Second,
+				Workers:        5,
+				MaxErrorRatio:  0.2,
+				ErrorWindow:    5 * time.Minute,
+				StaleIntervals: 3,
+			},
+		},
+	}
+}
+
+func load(t *testing.T, content string) *Config {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path, defaults())
+
+	if err != nil {
+		t.Fatalf("Load failed: %s", err)
+	}
+
+	return cfg
+}
+
+func TestLoadTargets(t *testing.T) {
+	cfg := load(t, `
+scrape:
+  workers: 10
+collectors:
+  intervals:
+    alarms: 1m
+targets:
+  - name: prod
+  - name: lab
+    vmanage:
+      endpoint: https://lab.example.com
+    scrape:
+      bulk: true
+    collectors:
+      intervals:
+        certificates: 1h
+`)
+
+	targets := cfg.TargetList()
+
+	if len(targets) != 2 {
+		t.Fatalf("Expected 2 targets, got %d", len(targets))
+	}
+
+	prod, lab := targets[0], targets[1]
+
+	if prod.VManage.Endpoint != "https://vmanage.example.com" || prod.Scrape.Workers != 10 || prod.Scrape.Bulk {
+		t.Errorf("Expected prod to inherit the top level settings, got %+v", prod)
+	}
+
+	if lab.VManage.Endpoint != "https://lab.example.com" || lab.Scrape.Workers != 10 || !lab.Scrape.Bulk {
+		t.Errorf("Expected lab to override the top level settings, got %+v", lab)
+	}
+
+	if d := lab.Interval("alarms"); d != time.Minute {
+		t.Errorf("Expected lab to inherit the alarms interval, got %s", d)
+	}
+
+	if d := lab.Interval("certificates"); d != time.Hour {
+		t.Errorf("Expected certificates interval of 1h, got %s", d)
+	}
+
+	if d := prod.Interval("certificates"); d != 30*time.Second {
+		t.Errorf("Expected prod to keep the scrape interval for certificates, got %s", d)
+	}
+}
+
+func TestLoadMaxErrors(t *testing.T) {
+	cfg := load(t, "scrape:\n  max_errors: 25\n")
+
+	if s := cfg.TargetList()[0].Scrape; s.MaxErrors != 25 || s.MaxErrorRatio != 0.2 {
+		t.Errorf("Expected deprecated max_errors to be loaded next to the error ratio, got %+v", s)
+	}
+}
+
+func TestLoadUnknownField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+
+	if err := os.WriteFile(path, []byte("scrape:\n  workerz: 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(path, defaults()); err == nil {
+		t.Fatal("Expected unknown field to fail")
+	}
+}
+
+func TestTargetListName(t *testing.T) {
+	cfg := defaults()
+
+	if name := cfg.TargetList()[0].Name; name != "vmanage.example.com" {
+		t.Errorf("Expected name from endpoint host, got %q", name)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Setenv("TEST_VMANAGE_USER", "admin")
+	t.Setenv("TEST_VMANAGE_PASSWORD", "secret")
+
+	cfg := defaults()
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected defaults to be valid, got %s", err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		modify   func(c *Config)
+		expected string
+	}{
+		{"endpoint", func(c *Config) { c.VManage.Endpoint = "vmanage" }, "vmanage.endpoint"},
+		{"endpoints", func(c *Config) { c.VManage.Endpoints = []string{"vmanage-2"} }, "vmanage.endpoints"},
+		{"health interval", func(c *Config) {
+			c.VManage.Endpoints = []string{"https://vmanage-2"}
+			c.VManage.HealthInterval = 0
+		}, "vmanage.health_interval"},
+		{"workers", func(c *Config) { c.Scrape.Workers = 0 }, "scrape.workers"},
+		{"error ratio", func(c *Config) { c.Scrape.MaxErrorRatio = 2 }, "scrape.max_error_ratio"},
+		{"max errors", func(c *Config) { c.Scrape.MaxErrors = -1 }, "scrape.max_errors"},
+		{"collector", func(c *Config) { c.Collectors.Enabled = []string{"foo"} }, `unknown collector "foo"`},
+		{"interval", func(c *Config) { c.Collectors.Intervals = map[string]time.Duration{"bfd": -time.Second} }, "interval of bfd"},
+		{"label", func(c *Config) { c.Labels.DeviceInfo = []string{"Foo"} }, `unknown label "Foo"`},
+		{"filter", func(c *Config) { c.Filters.Hostname = "(" }, "filters.hostname"},
+		{"auth", func(c *Config) { c.VManage.Auth = "basic" }, `unknown method "basic"`},
+		{"retry", func(c *Config) { c.VManage.RetryMaxWait = time.Millisecond }, "vmanage.retry_wait"},
+		{"target name", func(c *Config) { c.Targets = []Target{c.Target, c.Target} }, "name is required"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := defaults()
+			tc.modify(&c)
+
+			err := c.Validate()
+
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
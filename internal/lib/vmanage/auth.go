@@ -0,0 +1,317 @@
+package vmanage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"math"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrJWTUnsupported is returned by JWTAuth if vManage does not offer JWT authentication, which was added in 20.12.
+var ErrJWTUnsupported = errors.New("JWT authentication not supported")
+
+// Authenticator logs in to vManage and adds the credentials to requests.
+// Its methods are called with the lock of the client held.
+type Authenticator interface {
+	// Login authenticates with the username and password of the client or renews the current credentials.
+	// ctx limits the wait for the rate limit and the login requests.
+	Login(ctx context.Context, c *Client) error
+	// Logout invalidates the current credentials.
+	Logout(c *Client) error
+	// Authenticate adds the credentials to r. It returns false if a login is needed first,
+	// because there are no credentials or they are about to expire.
+	Authenticate(r *resty.Request) bool
+	// ID identifies the current credentials, it changes with every login.
+	ID() string
+}
+
+// SessionAuth logs in with a form and authenticates requests with the session cookie and an XSRF token.
+// It is supported by all vManage versions.
+type SessionAuth struct {
+	Session *http.Cookie
+	Token   string
+
+	// node is the URL of the cluster node the session belongs to, it is logged out there
+	node string
+}
+
+func (a *SessionAuth) Login(ctx context.Context, c *Client) error {
+	if a.Session != nil {
+		_ = a.Logout(c)
+	}
+
+	a.node = c.endpoint()
+	loginResp, err := c.send(ctx, http.MethodPost, "/j_security_check", c.httpClient().R().
+		SetFormData(map[string]string{"j_username": c.Username, "j_password": c.Password}))
+
+	if err != nil {
+		return err
+	}
+
+	for _, cookie := range loginResp.Cookies() {
+		if cookie.Name == "JSESSIONID" {
+			a.Session = cookie
+		}
+	}
+
+	if loginResp.StatusCode() != http.StatusOK || a.Session == nil {
+		return errors.New("Login error")
+	}
+
+	// fetch token
+	tokenResp, err := c.send(ctx, http.MethodGet, "/dataservice/client/token", c.httpClient().R().SetCookie(a.Session))
+
+	if err != nil {
+		return fmt.Errorf("Error fetching token: %w", err)
+	}
+
+	a.Token = tokenResp.String()
+	return nil
+}
+
+func (a *SessionAuth) Logout(c *Client) error {
+	if a.Session == nil {
+		a.Token = ""
+		return nil
+	}
+
+	node := a.node
+
+	if node == "" {
+		node = c.endpoint()
+	}
+
+	// a node which failed does not answer, its sessions are dropped without waiting for it
+	if c.isDown(node) {
+		a.Token = ""
+		a.Session = nil
+		return nil
+	}
+
+	r := c.httpClient().R()
+	a.Authenticate(r)
+
+	rnd, _ := rand.Int(rand.Reader, big.NewInt(int64(math.Pow10(9))))
+	resp, err := r.Get(node + fmt.Sprintf("/logout?nocache=%s", rnd))
+
+	a.Token = ""
+	a.Session = nil
+
+	if err != nil {
+		return err
+	}
+
+	// If the http response code is 302 redirect with location header
+	// https://{vmanage-ip-address}/welcome.html?nocache=, the session has been invalidated.
+	// Otherwise, an error occurred in the session invalidation process.
+	if resp.RawResponse.Request.URL.Path != "/welcome.html" {
+		return errors.New("Logout did not return redirect to welcome.html")
+	}
+
+	return nil
+}
+
+func (a *SessionAuth) Authenticate(r *resty.Request) bool {
+	if a.Session == nil || a.Token == "" {
+		return false
+	}
+
+	r.SetCookie(a.Session).SetHeader("X-XSRF-TOKEN", a.Token)
+	return true
+}
+
+func (a *SessionAuth) ID() string {
+	return a.Token
+}
+
+// JWTAuth authenticates requests with a bearer token issued by /jwt/login. The token is refreshed
+// when less than a tenth of its lifetime is left. Tokens cannot be invalidated, they expire after Duration.
+type JWTAuth struct {
+	// Duration is the requested lifetime of tokens, vManage issues tokens valid for 30 minutes by default.
+	Duration time.Duration
+
+	token   string
+	csrf    string
+	refresh string
+	issued  time.Time
+	expires time.Time
+}
+
+type jwtResponse struct {
+	Token   string `json:"token"`
+	CSRF    string `json:"csrf"`
+	Refresh string `json:"refresh"`
+}
+
+func (a *JWTAuth) Login(ctx context.Context, c *Client) error {
+	if a.refresh != "" && time.Now().Before(a.expires) {
+		err := a.login(ctx, c, "/jwt/refresh", map[string]interface{}{"refresh": a.refresh})
+
+		if err == nil {
+			return nil
+		}
+
+		// log in again below
+		a.refresh = ""
+	}
+
+	body := map[string]interface{}{"username": c.Username, "password": c.Password}
+
+	if a.Duration > 0 {
+		body["duration"] = int(a.Duration.Seconds())
+	}
+
+	return a.login(ctx, c, "/jwt/login", body)
+}
+
+func (a *JWTAuth) login(ctx context.Context, c *Client, endpoint string, body map[string]interface{}) error {
+	resp, err := c.send(ctx, http.MethodPost, endpoint, c.httpClient().R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(body))
+
+	if err != nil {
+		return err
+	}
+
+	// older versions do not know the endpoint and respond with 404 or the login page
+	if resp.StatusCode() == http.StatusNotFound || strings.HasPrefix(resp.Header().Get("Content-Type"), "text/html") {
+		return ErrJWTUnsupported
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("Login error: %s", resp.Status())
+	}
+
+	var res jwtResponse
+
+	if err := json.Unmarshal(resp.Body(), &res); err != nil || res.Token == "" {
+		return errors.New("Login error: no token in response")
+	}
+
+	a.token = res.Token
+	a.csrf = res.CSRF
+	a.issued = time.Now()
+	a.expires = expiry(res.Token, a.issued, a.Duration)
+
+	if res.Refresh != "" {
+		a.refresh = res.Refresh
+	}
+
+	return nil
+}
+
+func (a *JWTAuth) Logout(c *Client) error {
+	a.token = ""
+	a.csrf = ""
+	a.refresh = ""
+
+	return nil
+}
+
+func (a *JWTAuth) Authenticate(r *resty.Request) bool {
+	if a.token == "" || time.Now().Add(a.expires.Sub(a.issued)/10).After(a.expires) {
+		return false
+	}
+
+	r.SetAuthToken(a.token)
+
+	if a.csrf != "" {
+		r.SetHeader("X-XSRF-TOKEN", a.csrf)
+	}
+
+	return true
+}
+
+func (a *JWTAuth) ID() string {
+	return a.token
+}
+
+// expiry returns the expiration time of a JWT. If the token cannot be parsed or expires before it
+// was issued, it is assumed to be valid for duration or the default 30 minutes.
+func expiry(token string, issued time.Time, duration time.Duration) time.Time {
+	if parts := strings.Split(token, "."); len(parts) == 3 {
+		var claims struct {
+			Exp int64 `json:"exp"`
+		}
+
+		if b, err := base64.RawURLEncoding.DecodeString(parts[1]); err == nil {
+			// an expiry before the token was received is caused by clock skew and not used
+			if err := json.Unmarshal(b, &claims); err == nil && time.Unix(claims.Exp, 0).After(issued) {
+				return time.Unix(claims.Exp, 0)
+			}
+		}
+	}
+
+	if duration <= 0 {
+		duration = 30 * time.Minute
+	}
+
+	return issued.Add(duration)
+}
+
+// AutoAuth uses JWT authentication if vManage supports it and falls back to session authentication
+// if the JWT login is rejected.
+type AutoAuth struct {
+	JWT     JWTAuth
+	Session SessionAuth
+
+	selected Authenticator
+}
+
+func (a *AutoAuth) Login(ctx context.Context, c *Client) error {
+	if a.selected != nil {
+		return a.selected.Login(ctx, c)
+	}
+
+	err := a.JWT.Login(ctx, c)
+
+	if err == nil {
+		a.selected = &a.JWT
+		return nil
+	}
+
+	// only retry with a session if vManage responded, other errors would fail the same way
+	if ctx.Err() != nil || unavailable(ctx, nil, err) {
+		return err
+	}
+
+	if err := a.Session.Login(ctx, c); err != nil {
+		return err
+	}
+
+	a.selected = &a.Session
+	return nil
+}
+
+func (a *AutoAuth) Logout(c *Client) error {
+	if a.selected == nil {
+		return nil
+	}
+
+	return a.selected.Logout(c)
+}
+
+func (a *AutoAuth) Authenticate(r *resty.Request) bool {
+	return a.selected != nil && a.selected.Authenticate(r)
+}
+
+func (a *AutoAuth) ID() string {
+	if a.selected == nil {
+		return ""
+	}
+
+	return a.selected.ID()
+}
+
+// Selected returns the authenticator chosen on the first login, nil before.
+func (a *AutoAuth) Selected() Authenticator {
+	return a.selected
+}
@@ -0,0 +1,111 @@
+package vmanage_test
+
+import (
+	"context"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+	"net/http"
+	"testing"
+)
+
+func TestAutoAuthJWT(t *testing.T) {
+	c, _ := newClient(t)
+	auth := &vmanage.AutoAuth{}
+	c.Auth = auth
+
+	if _, err := c.Device(context.Background()); err != nil {
+		t.Fatalf("Error fetching devices: %s", err)
+	}
+
+	if _, ok := auth.Selected().(*vmanage.JWTAuth); !ok {
+		t.Errorf("Expected JWT authentication, got %T", auth.Selected())
+	}
+}
+
+func TestAutoAuthFallback(t *testing.T) {
+	c, srv := newClient(t)
+	srv.DisableJWT()
+	auth := &vmanage.AutoAuth{}
+	c.Auth = auth
+
+	if _, err := c.Device(context.Background()); err != nil {
+		t.Fatalf("Error fetching devices: %s", err)
+	}
+
+	if _, ok := auth.Selected().(*vmanage.SessionAuth); !ok {
+		t.Errorf("Expected session authentication, got %T", auth.Selected())
+	}
+
+	if err := c.Logout(); err != nil {
+		t.Fatalf("Logout failed: %s", err)
+	}
+
+	if n := srv.Sessions(); n != 0 {
+		t.Errorf("Expected no open sessions after logout, got %d", n)
+	}
+}
+
+func TestAutoAuthFallbackRejected(t *testing.T) {
+	for _, code := range []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusOK} {
+		c, srv := newClient(t)
+		srv.Fail("/jwt/login", code)
+		auth := &vmanage.AutoAuth{}
+		c.Auth = auth
+
+		if err := c.Login(context.Background()); err != nil {
+			t.Fatalf("Login after JWT login responded with %d failed: %s", code, err)
+		}
+
+		if _, ok := auth.Selected().(*vmanage.SessionAuth); !ok {
+			t.Errorf("Expected session authentication after JWT login responded with %d, got %T", code, auth.Selected())
+		}
+	}
+}
+
+func TestJWTUnsupported(t *testing.T) {
+	c, srv := newClient(t)
+	srv.DisableJWT()
+	c.Auth = &vmanage.JWTAuth{}
+
+	if err := c.Login(context.Background()); err == nil {
+		t.Fatal("Expected JWT login to fail")
+	}
+}
+
+func TestJWTInvalidCredentials(t *testing.T) {
+	c, _ := newClient(t)
+	c.Password = "wrong"
+	auth := &vmanage.AutoAuth{}
+	c.Auth = auth
+
+	if err := c.Login(context.Background()); err == nil {
+		t.Fatal("Expected login with invalid credentials to fail")
+	}
+
+	if auth.Selected() != nil {
+		t.Errorf("Expected no authentication to be selected, got %T", auth.Selected())
+	}
+}
+
+func TestJWTRefresh(t *testing.T) {
+	c, srv := newClient(t)
+	c.Auth = &vmanage.JWTAuth{}
+	ctx := context.Background()
+
+	if _, err := c.Device(ctx); err != nil {
+		t.Fatalf("Error fetching devices: %s", err)
+	}
+
+	srv.ExpireSessions()
+
+	if _, err := c.Device(ctx); err != nil {
+		t.Fatalf("Error fetching devices with expired token: %s", err)
+	}
+
+	if n := srv.Logins(); n != 1 {
+		t.Errorf("Expected 1 login, got %d", n)
+	}
+
+	if n := srv.Refreshes(); n != 1 {
+		t.Errorf("Expected 1 refresh, got %d", n)
+	}
+}
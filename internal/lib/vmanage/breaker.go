@@ -0,0 +1,139 @@
+package vmanage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without sending a request while the circuit breaker is open.
+var ErrCircuitOpen = errors.New("Circuit breaker open")
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker stops requests to vManage after Threshold consecutive failed requests. Once Timeout
+// passed, a single request is let through: if it succeeds, the breaker closes again.
+// Only errors indicating that vManage is unavailable count as failures, see retryable.
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	timeout   time.Duration
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	opened    int
+	probing   bool
+}
+
+// NewBreaker returns a closed circuit breaker. A threshold of 0 disables it.
+func NewBreaker(threshold int, timeout time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		timeout:   timeout,
+	}
+}
+
+// Configure changes threshold and timeout of the breaker, keeping its state.
+func (b *Breaker) Configure(threshold int, timeout time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.threshold = threshold
+	b.timeout = timeout
+}
+
+// Allow returns ErrCircuitOpen if a request must not be sent. A nil Breaker allows all requests.
+func (b *Breaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.timeout {
+			return ErrCircuitOpen
+		}
+
+		b.state = BreakerHalfOpen
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+	default:
+		return nil
+	}
+
+	b.probing = true
+	return nil
+}
+
+// Record updates the breaker with the outcome of a request allowed before.
+func (b *Breaker) Record(err error) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+	}
+
+	switch {
+	case err != nil && retryable(err):
+		b.failures++
+
+		if b.state == BreakerHalfOpen || (b.threshold > 0 && b.failures >= b.threshold && b.state == BreakerClosed) {
+			b.state = BreakerOpen
+			b.openedAt = time.Now()
+			b.opened++
+		}
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		// the request was aborted by the caller and says nothing about vManage
+	default:
+		b.failures = 0
+		b.state = BreakerClosed
+	}
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.timeout {
+		return BreakerHalfOpen
+	}
+
+	return b.state
+}
+
+// Opened returns how often the breaker opened.
+func (b *Breaker) Opened() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.opened
+}
@@ -1,165 +1,442 @@
 package vmanage
 
 import (
+	"bytes"
 	"context"
-	"crypto/rand"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/go-resty/resty/v2"
-	"io"
-	"math"
-	"math/big"
+	"golang.org/x/time/rate"
+	"net"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
+	"strings"
+	"sync"
 	"time"
 )
 
+// ErrSessionExpired is returned when vManage no longer accepts the current session.
+var ErrSessionExpired = errors.New("Session expired")
+
+// ErrThrottled is returned when vManage rejects a request because it is overloaded or rate limited.
+var ErrThrottled = errors.New("Throttled")
+
 type Client struct {
-	BaseURL         string
+	// BaseURL is the preferred node of a vManage cluster.
+	BaseURL string
+	// Endpoints are the further nodes of the cluster. Requests fail over to the first healthy node
+	// if the active node is unavailable and return to a preferred node once it is healthy again.
+	Endpoints []string
+	// HealthInterval is the interval at which preferred nodes are checked while requests failed over.
+	HealthInterval  time.Duration
 	Username        string
 	Password        string
-	Session         *http.Cookie
-	Token           string
 	TLSClientConfig *tls.Config
+	// Auth logs in and authenticates requests. It defaults to AutoAuth.
+	Auth Authenticator
+
+	// Timeout limits the duration of a single API request.
+	Timeout time.Duration
+	// PoolSize is the maximum number of connections kept open to vManage.
+	PoolSize int
+	// IdleConnTimeout closes pooled connections which were not used for this duration.
+	IdleConnTimeout time.Duration
+	// PageSize is the number of records requested per page from paginated endpoints.
+	PageSize int
+	// RateLimit is the maximum number of requests per second sent to vManage, 0 disables the limit.
+	RateLimit float64
+	// RateBurst is the number of requests which may exceed RateLimit at once.
+	RateBurst int
+	// Retries is the number of times a request failing with a retryable error is repeated.
+	Retries int
+	// RetryWait is the initial wait before a retry, it doubles with every attempt up to RetryMaxWait.
+	RetryWait    time.Duration
+	RetryMaxWait time.Duration
+	// Breaker stops requests while vManage is unavailable, if set.
+	Breaker *Breaker
+	// OnRetry is called before a request is repeated, if set.
+	OnRetry func(endpoint string, err error)
+	// OnRequest is called after every request to vManage, if set. code is 0 if no response was received.
+	OnRequest func(method string, endpoint string, code int, duration time.Duration, err error)
+	// OnFailover is called after requests switched from one node to another, if set. cause is nil
+	// when requests return to a preferred node.
+	OnFailover func(from string, to string, cause error)
+	// RecordDir stores every response of vManage in this directory, with secrets redacted, if set.
+	RecordDir string
+	// ReplayDir serves the responses recorded in this directory instead of sending requests to vManage, if set.
+	ReplayDir string
+
+	// mu guards Auth and the active node, or the VSessionId of a tenant client
+	mu sync.Mutex
+
+	// active is the index of the node requests are sent to, down holds the nodes which failed.
+	// nodesMu guards them for Nodes, changes are made with mu held as well.
+	nodesMu sync.Mutex
+	active  int
+	down    map[string]bool
+	checked time.Time
+
+	// provider is the client of the provider login a tenant client shares, see ForTenant and WithObserver.
+	provider     *Client
+	tenantID     string
+	vsessionID   string
+	vsessionAuth string
+
+	httpOnce sync.Once
+	rest     *resty.Client
+	limiter  *rate.Limiter
 }
 
 type FetchOptions interface {
 	Params() url.Values
 }
 
-func (c *Client) Login() error {
-	if c.Session != nil {
-		_ = c.Logout()
-	}
+// Login logs in, ctx limits the wait for the rate limit and the login requests.
+func (c *Client) Login(ctx context.Context) error {
+	p := c.shared()
+	p.mu.Lock()
+	err := p.login(ctx)
+	from := p.endpoint()
+	p.mu.Unlock()
+
+	if unavailable(ctx, nil, err) && len(p.Endpoints) > 0 {
+		if _, ferr := p.switchNode(ctx, from, err); ferr != nil {
+			return fmt.Errorf("%s, failover failed: %w", err, ferr)
+		}
 
-	jar, _ := cookiejar.New(nil)
-	client := &http.Client{
-		Jar: jar,
-		Transport: &http.Transport{
-			TLSClientConfig: c.TLSClientConfig,
-		},
-		Timeout: 10 * time.Second,
+		return nil
 	}
 
-	loginResp, err := client.PostForm(
-		c.BaseURL+"/j_security_check",
-		url.Values{"j_username": {c.Username}, "j_password": {c.Password}},
-	)
+	return err
+}
 
-	if err != nil {
-		return err
+func (c *Client) login(ctx context.Context) error {
+	return c.authenticator().Login(ctx, c)
+}
+
+// authenticator returns Auth, which defaults to AutoAuth.
+func (c *Client) authenticator() Authenticator {
+	if c.Auth == nil {
+		c.Auth = &AutoAuth{}
 	}
 
-	defer loginResp.Body.Close()
+	return c.Auth
+}
 
-	for _, cookie := range loginResp.Cookies() {
-		if cookie.Name == "JSESSIONID" {
-			c.Session = cookie
-		}
+// send issues a request to the active node within the rate limit, used to log in.
+// Server errors are returned as StatusError. The caller has to hold c.mu.
+func (c *Client) send(ctx context.Context, method string, endpoint string, r *resty.Request) (*resty.Response, error) {
+	if err := c.wait(ctx); err != nil {
+		return nil, err
 	}
 
-	if loginResp.StatusCode != http.StatusOK || c.Session == nil {
-		return errors.New("Login error")
+	startTime := time.Now()
+	resp, err := r.SetContext(ctx).Execute(method, c.url(endpoint))
+
+	if err == nil && resp.StatusCode() >= http.StatusInternalServerError {
+		err = &StatusError{StatusCode: resp.StatusCode(), Status: resp.Status(), Body: resp.String()}
 	}
 
-	// fetch token
-	tokenResp, err := client.Get(c.BaseURL + "/dataservice/client/token")
+	c.observe(method, endpoint, resp, startTime, err)
 
-	if err != nil {
-		return fmt.Errorf("Error fetching token: %w", err)
+	return resp, err
+}
+
+// relogin replaces the credentials identified by id, which were used to issue a request.
+// If another request already renewed them in the meantime, the new credentials are kept.
+func (c *Client) relogin(ctx context.Context, id string) error {
+	p := c.shared()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if current := p.authenticator().ID(); current != id && current != "" {
+		return nil
 	}
 
-	defer tokenResp.Body.Close()
+	return p.login(ctx)
+}
 
-	t, err := io.ReadAll(tokenResp.Body)
+func (c *Client) Request(ctx context.Context) (*resty.Request, error) {
+	p := c.shared()
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	if err != nil {
-		return err
+	return p.request(ctx)
+}
+
+func (c *Client) request(ctx context.Context) (*resty.Request, error) {
+	r := c.httpClient().R()
+
+	if c.authenticator().Authenticate(r) {
+		return r, nil
 	}
 
-	c.Token = string(t)
-	return nil
+	if err := c.login(ctx); err != nil {
+		return nil, fmt.Errorf("Login failed: %w", err)
+	}
+
+	if !c.Auth.Authenticate(r) {
+		return nil, errors.New("Login failed: no credentials")
+	}
+
+	return r, nil
 }
 
-func (c *Client) Request() (*resty.Request, error) {
-	if c.Session == nil || c.Token == "" {
-		if err := c.Login(); err != nil {
-			return nil, fmt.Errorf("Login failed: %w", err)
-		}
+// httpClient returns the connection pool shared by all requests. It is created on first use,
+// so the exported options have to be set before the client is used.
+func (c *Client) httpClient() *resty.Client {
+	if c.provider != nil {
+		return c.provider.httpClient()
 	}
 
-	rc := resty.New()
-	rc.DisableWarn = true
-	rc.SetCookie(c.Session)
-	rc.SetHeader("X-XSRF-TOKEN", c.Token)
-	rc.SetTLSClientConfig(c.TLSClientConfig)
-	rc.SetTimeout(10 * time.Second)
-	rc.SetBaseURL(c.BaseURL)
+	c.httpOnce.Do(func() {
+		transport := &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   c.Timeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSClientConfig:     c.TLSClientConfig,
+			TLSHandshakeTimeout: c.Timeout,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        c.PoolSize,
+			MaxIdleConnsPerHost: c.PoolSize,
+			MaxConnsPerHost:     c.PoolSize,
+			IdleConnTimeout:     c.IdleConnTimeout,
+		}
+
+		var rt http.RoundTripper = transport
 
-	return rc.R(), nil
+		switch {
+		case c.ReplayDir != "":
+			rt = &replayTransport{dir: c.ReplayDir}
+		case c.RecordDir != "":
+			rt = &recordTransport{dir: c.RecordDir, next: transport}
+		}
+
+		c.rest = resty.New().
+			SetTransport(rt).
+			SetCookieJar(nil).
+			SetTimeout(c.Timeout).
+			SetBaseURL(c.BaseURL)
+		c.rest.DisableWarn = true
+
+		if c.RateLimit > 0 {
+			c.limiter = rate.NewLimiter(rate.Limit(c.RateLimit), c.RateBurst)
+		}
+	})
+
+	return c.rest
 }
 
 func (c *Client) Fetch(ctx context.Context, endpoint string, options FetchOptions, results interface{}) (interface{}, error) {
-	r, err := c.Request()
+	if options != nil {
+		endpoint += "?" + options.Params().Encode()
+	}
 
-	if err != nil {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.fetch(ctx, endpoint, results)
+
+		if err == nil {
+			return resp.Result(), nil
+		}
+
+		if attempt >= c.Retries || !retryable(err) {
+			return nil, err
+		}
+
+		if c.OnRetry != nil {
+			c.OnRetry(endpoint, err)
+		}
+
+		select {
+		case <-time.After(c.backoff(attempt, resp)):
+		case <-ctx.Done():
+			return nil, err
+		}
+	}
+}
+
+// fetch issues a request through the circuit breaker and logs in again once if the session expired.
+// If the node is unavailable, the request is repeated once on another node of the cluster.
+// The response is returned with errors, if one was received.
+func (c *Client) fetch(ctx context.Context, endpoint string, results interface{}) (*resty.Response, error) {
+	if err := c.Breaker.Allow(); err != nil {
 		return nil, err
 	}
 
-	r.SetContext(ctx)
+	node := c.node(ctx)
+	resp, id, err := c.get(ctx, endpoint, results)
 
-	if options != nil {
-		endpoint += "?" + options.Params().Encode()
+	if errors.Is(err, ErrSessionExpired) {
+		if err = c.relogin(ctx, id); err != nil {
+			err = fmt.Errorf("Re-login failed: %w", err)
+		} else {
+			resp, _, err = c.get(ctx, endpoint, results)
+		}
 	}
 
-	resp, err := r.SetResult(results).Get(endpoint)
+	if unavailable(ctx, resp, err) {
+		switched, ferr := c.failover(ctx, node, err)
+
+		switch {
+		case ferr != nil:
+			err = fmt.Errorf("%w, failover failed: %s", err, ferr)
+		case switched:
+			resp, _, err = c.get(ctx, endpoint, results)
+		}
+	}
+
+	c.Breaker.Record(err)
+	return resp, err
+}
+
+// get issues a single GET request and returns the id of the credentials it was sent with.
+// The response is returned with errors, if one was received.
+func (c *Client) get(ctx context.Context, endpoint string, results interface{}) (*resty.Response, string, error) {
+	p := c.shared()
+	p.mu.Lock()
+	r, err := p.request(ctx)
+	id := p.authenticator().ID()
+	u := p.url(endpoint)
+	p.mu.Unlock()
 
 	if err != nil {
-		return nil, err
+		return nil, id, err
 	}
 
-	if resp.IsError() {
-		return nil, fmt.Errorf("%s: %s", resp.Status(), resp.String())
+	if c.tenantID != "" {
+		vsessionID, err := c.vsession(ctx, id)
+
+		if err != nil {
+			return nil, id, fmt.Errorf("Error selecting tenant %s: %w", c.tenantID, err)
+		}
+
+		r.SetHeader("VSessionId", vsessionID)
+		ctx = context.WithValue(ctx, tenantKey{}, c.tenantID)
+	}
+
+	if err := c.wait(ctx); err != nil {
+		return nil, id, err
 	}
 
-	return resp.Result(), nil
+	startTime := time.Now()
+	resp, err := r.SetContext(ctx).SetResult(results).Get(u)
+	err = checkResponse(resp, err)
+	c.observe(http.MethodGet, endpoint, resp, startTime, err)
+
+	return resp, id, err
 }
 
-func (c *Client) Logout() error {
-	if c.Session == nil {
-		c.Token = ""
+// wait blocks until the rate limit allows another request.
+func (c *Client) wait(ctx context.Context) error {
+	p := c.shared()
+	p.httpClient()
+
+	if p.limiter == nil {
 		return nil
 	}
 
-	r, err := c.Request()
+	return p.limiter.Wait(ctx)
+}
 
+// checkResponse turns error responses of vManage into errors.
+func checkResponse(resp *resty.Response, err error) error {
 	if err != nil {
 		return err
 	}
 
-	rnd, _ := rand.Int(rand.Reader, big.NewInt(int64(math.Pow10(9))))
-	resp, err := r.Get(fmt.Sprintf("%s/logout?nocache=%s", c.BaseURL, rnd))
+	if sessionExpired(resp) {
+		return fmt.Errorf("%w: %s", ErrSessionExpired, resp.Status())
+	}
 
-	c.Token = ""
-	c.Session = nil
+	switch resp.StatusCode() {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return fmt.Errorf("%w: %s", ErrThrottled, resp.Status())
+	}
 
-	// If the http response code is 302 redirect with location header
-	// https://{vmanage-ip-address}/welcome.html?nocache=, the session has been invalidated.
-	// Otherwise, an error occurred in the session invalidation process.
-	if resp.RawResponse.Request.URL.Path != "/welcome.html" {
-		return errors.New("Logout did not return redirect to welcome.html")
+	if resp.IsError() {
+		return &StatusError{StatusCode: resp.StatusCode(), Status: resp.Status(), Body: resp.String()}
 	}
 
 	return nil
 }
 
+// observe passes the outcome of a request to OnRequest. The query is stripped from the endpoint.
+func (c *Client) observe(method string, endpoint string, resp *resty.Response, startTime time.Time, err error) {
+	if c.OnRequest == nil {
+		return
+	}
+
+	code := 0
+
+	if resp != nil && resp.RawResponse != nil {
+		code = resp.StatusCode()
+	}
+
+	if i := strings.Index(endpoint, "?"); i >= 0 {
+		endpoint = endpoint[:i]
+	}
+
+	c.OnRequest(method, endpoint, code, time.Since(startTime), err)
+}
+
+// sessionExpired detects responses of vManage to requests with an invalid session:
+// 401, a redirect to the login page or an HTML page instead of JSON. 403 is returned for
+// endpoints the user has no permission for and does not expire the session.
+func sessionExpired(resp *resty.Response) bool {
+	if resp.StatusCode() == http.StatusUnauthorized {
+		return true
+	}
+
+	if raw := resp.RawResponse; raw != nil && raw.Request != nil && raw.Request.URL.Path == "/welcome.html" {
+		return true
+	}
+
+	if strings.HasPrefix(resp.Header().Get("Content-Type"), "text/html") {
+		return true
+	}
+
+	return bytes.HasPrefix(bytes.TrimSpace(resp.Body()), []byte("<"))
+}
+
+// Logout ends the login of c. Tenant clients and clients returned by WithObserver only discard their
+// VSessionId, the provider stays logged in.
+func (c *Client) Logout() error {
+	if c.provider != nil {
+		c.mu.Lock()
+		c.vsessionID = ""
+		c.mu.Unlock()
+
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.logout()
+}
+
+func (c *Client) logout() error {
+	return c.authenticator().Logout(c)
+}
+
 func NewClient(baseURL string, username string, password string) *Client {
 	return &Client{
 		BaseURL:         baseURL,
 		Username:        username,
 		Password:        password,
 		TLSClientConfig: &tls.Config{},
+		Auth:            &AutoAuth{},
+		Timeout:         10 * time.Second,
+		PoolSize:        10,
+		IdleConnTimeout: 90 * time.Second,
+		PageSize:        1000,
+		HealthInterval:  time.Minute,
+		RateBurst:       10,
+		Retries:         3,
+		RetryWait:       500 * time.Millisecond,
+		RetryMaxWait:    10 * time.Second,
 	}
 }
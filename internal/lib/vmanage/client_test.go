@@ -0,0 +1,238 @@
+package vmanage_test
+
+import (
+	"context"
+	"errors"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage/vmanagetest"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newClient(t *testing.T) (*vmanage.Client, *vmanagetest.Server) {
+	t.Helper()
+
+	srv := vmanagetest.NewServer()
+	t.Cleanup(srv.Close)
+
+	c := vmanage.NewClient(srv.URL, vmanagetest.Username, vmanagetest.Password)
+	c.RetryWait = time.Millisecond
+	c.RetryMaxWait = 10 * time.Millisecond
+
+	return c, srv
+}
+
+func TestLogin(t *testing.T) {
+	c, srv := newClient(t)
+	auth := &vmanage.SessionAuth{}
+	c.Auth = auth
+
+	if err := c.Login(context.Background()); err != nil {
+		t.Fatalf("Login failed: %s", err)
+	}
+
+	if auth.Session == nil || auth.Token == "" {
+		t.Fatalf("Expected session and token, got %v and %q", auth.Session, auth.Token)
+	}
+
+	if err := c.Logout(); err != nil {
+		t.Fatalf("Logout failed: %s", err)
+	}
+
+	if n := srv.Sessions(); n != 0 {
+		t.Errorf("Expected no open sessions after logout, got %d", n)
+	}
+}
+
+func TestLoginInvalidCredentials(t *testing.T) {
+	c, _ := newClient(t)
+	c.Password = "wrong"
+
+	if err := c.Login(context.Background()); err == nil {
+		t.Fatal("Expected login with invalid credentials to fail")
+	}
+}
+
+func TestDevice(t *testing.T) {
+	c, _ := newClient(t)
+
+	devices, err := c.Device(context.Background())
+
+	if err != nil {
+		t.Fatalf("Error fetching devices: %s", err)
+	}
+
+	if len(devices) != 2 {
+		t.Fatalf("Expected 2 devices, got %d", len(devices))
+	}
+
+	if d := devices[0]; d.Hostname != "edge-zrh-1" || !d.IsReachable() || !d.HasValidCertificate() {
+		t.Errorf("Unexpected device %+v", d)
+	}
+}
+
+func TestSessionExpired(t *testing.T) {
+	c, srv := newClient(t)
+	c.Auth = &vmanage.SessionAuth{}
+	ctx := context.Background()
+
+	if _, err := c.Device(ctx); err != nil {
+		t.Fatalf("Error fetching devices: %s", err)
+	}
+
+	srv.ExpireSessions()
+
+	if _, err := c.Device(ctx); err != nil {
+		t.Fatalf("Error fetching devices with expired session: %s", err)
+	}
+
+	if n := srv.Logins(); n != 2 {
+		t.Errorf("Expected 2 logins, got %d", n)
+	}
+}
+
+func TestForbidden(t *testing.T) {
+	c, srv := newClient(t)
+	ctx := context.Background()
+
+	if _, err := c.Device(ctx); err != nil {
+		t.Fatalf("Error fetching devices: %s", err)
+	}
+
+	srv.Fail("/dataservice/device", http.StatusForbidden)
+
+	var statusErr *vmanage.StatusError
+
+	if _, err := c.Device(ctx); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expected status error 403, got %v", err)
+	}
+
+	if n := srv.Logins(); n != 1 {
+		t.Errorf("Expected no login after 403, got %d logins", n)
+	}
+}
+
+func TestFetchAll(t *testing.T) {
+	c, srv := newClient(t)
+	c.PageSize = 1
+
+	ifs, err := c.DeviceStateInterface(context.Background())
+
+	if err != nil {
+		t.Fatalf("Error fetching interfaces: %s", err)
+	}
+
+	if len(ifs) != 3 {
+		t.Errorf("Expected 3 interfaces, got %d", len(ifs))
+	}
+
+	if n := srv.Requests("/dataservice/data/device/state/Interface"); n != 3 {
+		t.Errorf("Expected 3 requests, got %d", n)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	c, srv := newClient(t)
+	srv.Fail("/dataservice/device", http.StatusServiceUnavailable, http.StatusBadGateway)
+
+	if _, err := c.Device(context.Background()); err != nil {
+		t.Fatalf("Expected request to succeed after retries, got %s", err)
+	}
+
+	if n := srv.Requests("/dataservice/device"); n != 3 {
+		t.Errorf("Expected 3 requests, got %d", n)
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	c, srv := newClient(t)
+	c.Retries = 1
+	srv.Fail("/dataservice/device", http.StatusTooManyRequests, http.StatusTooManyRequests)
+
+	_, err := c.Device(context.Background())
+
+	if !errors.Is(err, vmanage.ErrThrottled) {
+		t.Fatalf("Expected ErrThrottled, got %v", err)
+	}
+
+	if n := srv.Requests("/dataservice/device"); n != 2 {
+		t.Errorf("Expected 2 requests, got %d", n)
+	}
+}
+
+func TestNoRetryOnClientError(t *testing.T) {
+	c, srv := newClient(t)
+	srv.Fail("/dataservice/device", http.StatusBadRequest)
+
+	_, err := c.Device(context.Background())
+
+	var statusErr *vmanage.StatusError
+
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected StatusError 400, got %v", err)
+	}
+
+	if n := srv.Requests("/dataservice/device"); n != 1 {
+		t.Errorf("Expected 1 request, got %d", n)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	c, srv := newClient(t)
+	c.Retries = 0
+	c.Breaker = vmanage.NewBreaker(2, time.Hour)
+	ctx := context.Background()
+
+	srv.Fail("/dataservice/device", http.StatusInternalServerError, http.StatusInternalServerError)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Device(ctx); err == nil {
+			t.Fatal("Expected request to fail")
+		}
+	}
+
+	if s := c.Breaker.State(); s != vmanage.BreakerOpen {
+		t.Fatalf("Expected breaker to be open, got %s", s)
+	}
+
+	if _, err := c.Device(ctx); !errors.Is(err, vmanage.ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+
+	if n := srv.Requests("/dataservice/device"); n != 2 {
+		t.Errorf("Expected no request while the breaker is open, got %d", n)
+	}
+
+	// let the next request through
+	c.Breaker.Configure(2, 0)
+
+	if _, err := c.Device(ctx); err != nil {
+		t.Fatalf("Expected request to succeed, got %s", err)
+	}
+
+	if s := c.Breaker.State(); s != vmanage.BreakerClosed {
+		t.Errorf("Expected breaker to be closed, got %s", s)
+	}
+}
+
+func TestRateLimitCancel(t *testing.T) {
+	c, _ := newClient(t)
+	c.Auth = &vmanage.SessionAuth{}
+	c.RateLimit = 0.001
+	c.RateBurst = 1
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	startTime := time.Now()
+
+	// the login request takes the burst, fetching the token has to wait for the rate limit
+	if err := c.Login(ctx); err == nil {
+		t.Fatal("Expected login to fail when the context ends while waiting for the rate limit")
+	}
+
+	if d := time.Since(startTime); d > time.Second {
+		t.Errorf("Expected login to return when the context ends, took %s", d)
+	}
+}
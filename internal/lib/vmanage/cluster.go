@@ -0,0 +1,225 @@
+package vmanage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ErrNoHealthyNode is returned when a request failed and no other node of the cluster passed the health check.
+var ErrNoHealthyNode = errors.New("No healthy vManage node")
+
+// healthEndpoint is requested to check whether a node is available. The login page is served without a session.
+const healthEndpoint = "/welcome.html"
+
+// Node is a vManage node requests can be sent to.
+type Node struct {
+	URL string
+	// Active is set for the node requests are currently sent to.
+	Active bool
+	// Up is false if the last request or health check of the node failed.
+	Up bool
+}
+
+// nodes returns the URLs of all nodes in order of preference.
+func (c *Client) nodes() []string {
+	return append([]string{c.BaseURL}, c.Endpoints...)
+}
+
+// endpoint returns the URL of the node requests are sent to. The caller has to hold c.mu.
+func (c *Client) endpoint() string {
+	return c.nodes()[c.active]
+}
+
+// url returns the URL of endpoint on the active node. The caller has to hold c.mu.
+func (c *Client) url(endpoint string) string {
+	return c.endpoint() + endpoint
+}
+
+// Nodes returns the state of the nodes of the cluster, the node at BaseURL first.
+func (c *Client) Nodes() []Node {
+	p := c.shared()
+	p.nodesMu.Lock()
+	defer p.nodesMu.Unlock()
+
+	var res []Node
+
+	for i, u := range p.nodes() {
+		res = append(res, Node{URL: u, Active: i == p.active, Up: !p.down[u]})
+	}
+
+	return res
+}
+
+// node returns the URL of the active node. While requests fail over to a less preferred node,
+// the preferred nodes are checked every HealthInterval and requests return to the first healthy one.
+func (c *Client) node(ctx context.Context) string {
+	p := c.shared()
+	p.mu.Lock()
+	from := p.active
+	due := from > 0 && p.HealthInterval > 0 && time.Since(p.checked) >= p.HealthInterval
+
+	if due {
+		p.checked = time.Now()
+	}
+	p.mu.Unlock()
+
+	if due {
+		p.failback(ctx, from)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.endpoint()
+}
+
+// failback switches from node from to the first healthy node preferred to it and logs in there,
+// unless another request switched nodes in the meantime. The nodes are checked without holding c.mu.
+// If the login fails, requests stay on node from.
+func (c *Client) failback(ctx context.Context, from int) {
+	nodes := c.nodes()
+
+	for i := 0; i < from; i++ {
+		if !c.check(ctx, nodes[i]) {
+			continue
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if c.active != from {
+			return
+		}
+
+		c.activate(i, nil)
+
+		if err := c.login(ctx); err != nil {
+			c.setDown(nodes[i], true)
+			c.activate(from, err)
+		}
+
+		return
+	}
+}
+
+// failover switches requests away from the node at from after a request to it failed with err,
+// unless another request did so already. It reports whether the active node changed.
+func (c *Client) failover(ctx context.Context, from string, err error) (bool, error) {
+	p := c.shared()
+	p.mu.Lock()
+	active := p.endpoint()
+	p.mu.Unlock()
+
+	if active != from {
+		return true, nil
+	}
+
+	if len(p.Endpoints) == 0 {
+		return false, nil
+	}
+
+	return p.switchNode(ctx, from, err)
+}
+
+// switchNode logs in on the first healthy node other than from, unless another request switched
+// away from it in the meantime. The nodes are checked without holding c.mu.
+func (c *Client) switchNode(ctx context.Context, from string, cause error) (bool, error) {
+	c.setDown(from, true)
+
+	for i, u := range c.nodes() {
+		if u == from || !c.check(ctx, u) {
+			continue
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if c.endpoint() != from {
+			return true, nil
+		}
+
+		c.activate(i, cause)
+
+		if err := c.login(ctx); err != nil {
+			return true, fmt.Errorf("Login on %s failed: %w", u, err)
+		}
+
+		return true, nil
+	}
+
+	return false, ErrNoHealthyNode
+}
+
+// activate sends requests to node i from now on, cause is the error which made the previous node fail.
+func (c *Client) activate(i int, cause error) {
+	from := c.endpoint()
+
+	c.nodesMu.Lock()
+	c.active = i
+	c.nodesMu.Unlock()
+
+	if c.OnFailover != nil {
+		c.OnFailover(from, c.endpoint(), cause)
+	}
+}
+
+// check requests the health endpoint of the node at u and records whether it is up.
+func (c *Client) check(ctx context.Context, u string) bool {
+	resp, err := c.httpClient().R().SetContext(ctx).Get(u + healthEndpoint)
+
+	// the node is not to blame if the caller gave up
+	if ctx.Err() != nil {
+		return false
+	}
+
+	up := err == nil && resp.StatusCode() < http.StatusInternalServerError
+
+	c.setDown(u, !up)
+	return up
+}
+
+// isDown reports whether the last request or health check of the node at u failed.
+func (c *Client) isDown(u string) bool {
+	c.nodesMu.Lock()
+	defer c.nodesMu.Unlock()
+
+	return c.down[u]
+}
+
+func (c *Client) setDown(u string, down bool) {
+	c.nodesMu.Lock()
+	defer c.nodesMu.Unlock()
+
+	if c.down == nil {
+		c.down = map[string]bool{}
+	}
+
+	c.down[u] = down
+}
+
+// unavailable reports whether a request failed because the node is unavailable: no response
+// was received within the client timeout or it responded with a server error. Requests aborted
+// through ctx do not count.
+func unavailable(ctx context.Context, resp *resty.Response, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	if resp != nil && resp.RawResponse != nil {
+		return resp.StatusCode() >= http.StatusInternalServerError
+	}
+
+	var statusErr *StatusError
+
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,238 @@
+package vmanage_test
+
+import (
+	"context"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage/vmanagetest"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newCluster(t *testing.T) (*vmanage.Client, *vmanagetest.Server, *vmanagetest.Server) {
+	t.Helper()
+
+	c, primary := newClient(t)
+	secondary := vmanagetest.NewServer()
+	t.Cleanup(secondary.Close)
+
+	c.Endpoints = []string{secondary.URL}
+
+	return c, primary, secondary
+}
+
+func active(c *vmanage.Client) string {
+	for _, n := range c.Nodes() {
+		if n.Active {
+			return n.URL
+		}
+	}
+
+	return ""
+}
+
+func TestFailover(t *testing.T) {
+	c, primary, secondary := newCluster(t)
+	ctx := context.Background()
+
+	var failovers []string
+	c.OnFailover = func(from string, to string, cause error) {
+		failovers = append(failovers, to)
+	}
+
+	if _, err := c.Device(ctx); err != nil {
+		t.Fatalf("Error fetching devices: %s", err)
+	}
+
+	primary.SetDown(true)
+
+	if _, err := c.Device(ctx); err != nil {
+		t.Fatalf("Error fetching devices after failover: %s", err)
+	}
+
+	if u := active(c); u != secondary.URL {
+		t.Fatalf("Expected %s to be active, got %s", secondary.URL, u)
+	}
+
+	if n := secondary.Logins(); n != 1 {
+		t.Errorf("Expected 1 login on the secondary node, got %d", n)
+	}
+
+	if len(failovers) != 1 || failovers[0] != secondary.URL {
+		t.Errorf("Expected failover to %s, got %v", secondary.URL, failovers)
+	}
+
+	for _, n := range c.Nodes() {
+		if n.URL == primary.URL && n.Up {
+			t.Errorf("Expected %s to be down", primary.URL)
+		}
+	}
+}
+
+func TestFailoverConnectionError(t *testing.T) {
+	c, primary, secondary := newCluster(t)
+	primary.Close()
+
+	// the initial login fails over as well
+	if err := c.Login(context.Background()); err != nil {
+		t.Fatalf("Login failed: %s", err)
+	}
+
+	if _, err := c.Device(context.Background()); err != nil {
+		t.Fatalf("Error fetching devices: %s", err)
+	}
+
+	if u := active(c); u != secondary.URL {
+		t.Fatalf("Expected %s to be active, got %s", secondary.URL, u)
+	}
+}
+
+func TestFailback(t *testing.T) {
+	c, primary, secondary := newCluster(t)
+	c.HealthInterval = time.Millisecond
+	ctx := context.Background()
+
+	primary.SetDown(true)
+
+	if _, err := c.Device(ctx); err != nil {
+		t.Fatalf("Error fetching devices: %s", err)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+
+	// still down, requests stay on the secondary node
+	if _, err := c.Device(ctx); err != nil {
+		t.Fatalf("Error fetching devices: %s", err)
+	}
+
+	if u := active(c); u != secondary.URL {
+		t.Fatalf("Expected %s to be active, got %s", secondary.URL, u)
+	}
+
+	primary.SetDown(false)
+	time.Sleep(2 * time.Millisecond)
+
+	if _, err := c.Device(ctx); err != nil {
+		t.Fatalf("Error fetching devices: %s", err)
+	}
+
+	if u := active(c); u != primary.URL {
+		t.Fatalf("Expected %s to be active again, got %s", primary.URL, u)
+	}
+
+	if n := primary.Logins(); n != 1 {
+		t.Errorf("Expected 1 login on the primary node, got %d", n)
+	}
+}
+
+func TestFailbackLogout(t *testing.T) {
+	c, primary, secondary := newCluster(t)
+	c.HealthInterval = time.Millisecond
+	c.Auth = &vmanage.SessionAuth{}
+	ctx := context.Background()
+
+	primary.SetDown(true)
+
+	if _, err := c.Device(ctx); err != nil {
+		t.Fatalf("Error fetching devices: %s", err)
+	}
+
+	if n := secondary.Sessions(); n != 1 {
+		t.Fatalf("Expected 1 session on the secondary node, got %d", n)
+	}
+
+	primary.SetDown(false)
+	time.Sleep(2 * time.Millisecond)
+
+	if _, err := c.Device(ctx); err != nil {
+		t.Fatalf("Error fetching devices: %s", err)
+	}
+
+	if u := active(c); u != primary.URL {
+		t.Fatalf("Expected %s to be active again, got %s", primary.URL, u)
+	}
+
+	if n := secondary.Sessions(); n != 0 {
+		t.Errorf("Expected session on the secondary node to be logged out, got %d sessions", n)
+	}
+
+	if err := c.Logout(); err != nil {
+		t.Fatalf("Logout failed: %s", err)
+	}
+
+	if n := primary.Sessions(); n != 0 {
+		t.Errorf("Expected no open sessions on the primary node after logout, got %d", n)
+	}
+}
+
+func TestFailoverAllDown(t *testing.T) {
+	c, primary, secondary := newCluster(t)
+	c.Retries = 0
+
+	primary.SetDown(true)
+	secondary.SetDown(true)
+
+	if _, err := c.Device(context.Background()); err == nil {
+		t.Fatal("Expected request to fail with all nodes down")
+	}
+
+	if u := active(c); u != primary.URL {
+		t.Errorf("Expected %s to stay active, got %s", primary.URL, u)
+	}
+}
+
+func TestFailoverHangingNode(t *testing.T) {
+	srv := vmanagetest.NewServer()
+	t.Cleanup(srv.Close)
+
+	target, _ := url.Parse(srv.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+
+	var hang int32
+	release := make(chan struct{})
+
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&hang) == 1 {
+			<-release
+			return
+		}
+
+		proxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(primary.Close)
+	t.Cleanup(func() { close(release) })
+
+	secondary := vmanagetest.NewServer()
+	t.Cleanup(secondary.Close)
+
+	c := vmanage.NewClient(primary.URL, vmanagetest.Username, vmanagetest.Password)
+	c.Endpoints = []string{secondary.URL}
+	c.Auth = &vmanage.SessionAuth{}
+	c.Timeout = time.Second
+	c.Retries = 0
+	ctx := context.Background()
+
+	if _, err := c.Device(ctx); err != nil {
+		t.Fatalf("Error fetching devices: %s", err)
+	}
+
+	atomic.StoreInt32(&hang, 1)
+	startTime := time.Now()
+
+	if _, err := c.Device(ctx); err != nil {
+		t.Fatalf("Error fetching devices after failover: %s", err)
+	}
+
+	// the request to the hanging node times out, the session on it is dropped without waiting again
+	if d := time.Since(startTime); d > 1900*time.Millisecond {
+		t.Errorf("Expected failover within the timeout of a single request, took %s", d)
+	}
+
+	if u := active(c); u != secondary.URL {
+		t.Errorf("Expected %s to be active, got %s", secondary.URL, u)
+	}
+}
@@ -0,0 +1,46 @@
+package vmanage
+
+import (
+	"encoding/base64"
+	"github.com/go-resty/resty/v2"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestJWTExpiry(t *testing.T) {
+	issued := time.Now()
+	exp := issued.Add(time.Hour).Truncate(time.Second)
+	token := "e30." + base64.RawURLEncoding.EncodeToString([]byte(`{"exp":`+strconv.FormatInt(exp.Unix(), 10)+`}`)) + ".c2ln"
+
+	if e := expiry(token, issued, time.Minute); !e.Equal(exp) {
+		t.Errorf("Expected expiry %s from token, got %s", exp, e)
+	}
+
+	if e := expiry("opaque", issued, time.Minute); !e.Equal(issued.Add(time.Minute)) {
+		t.Errorf("Expected expiry after duration, got %s", e)
+	}
+
+	skewed := "e30." + base64.RawURLEncoding.EncodeToString([]byte(`{"exp":`+strconv.FormatInt(issued.Add(-time.Hour).Unix(), 10)+`}`)) + ".c2ln"
+
+	if e := expiry(skewed, issued, time.Minute); !e.Equal(issued.Add(time.Minute)) {
+		t.Errorf("Expected expiry after duration for token expired on issue, got %s", e)
+	}
+}
+
+func TestJWTRefreshBeforeExpiry(t *testing.T) {
+	now := time.Now()
+	r := resty.New().R()
+
+	a := &JWTAuth{token: "t", issued: now.Add(-50 * time.Minute), expires: now.Add(10 * time.Minute)}
+
+	if !a.Authenticate(r) {
+		t.Error("Expected token with a sixth of its lifetime left to be used")
+	}
+
+	a = &JWTAuth{token: "t", issued: now.Add(-55 * time.Minute), expires: now.Add(5 * time.Minute)}
+
+	if a.Authenticate(r) {
+		t.Error("Expected token with less than a tenth of its lifetime left to be refreshed")
+	}
+}
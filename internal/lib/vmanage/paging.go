@@ -0,0 +1,98 @@
+package vmanage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// ErrPagingStalled is returned if vManage announces more data, but returns an empty page or a cursor it returned before.
+var ErrPagingStalled = errors.New("Paging stalled")
+
+// PageInfo describes the position of a response within a paginated result set.
+// Statistics endpoints paginate with a scroll id, state endpoints with the id of the last record.
+type PageInfo struct {
+	ScrollID    string `json:"scrollId,omitempty"`
+	HasMoreData bool   `json:"hasMoreData,omitempty"`
+	StartID     string `json:"startId,omitempty"`
+	EndID       string `json:"endId,omitempty"`
+	MoreEntries bool   `json:"moreEntries,omitempty"`
+	Count       int    `json:"count"`
+}
+
+// next sets the query parameters to request the following page and returns its cursor,
+// or an empty string if this is the last page.
+func (p PageInfo) next(params url.Values) string {
+	switch {
+	case p.ScrollID != "" && p.HasMoreData:
+		params.Set("scrollId", p.ScrollID)
+		return p.ScrollID
+	case p.EndID != "" && p.MoreEntries:
+		params.Set("startId", p.EndID)
+		return p.EndID
+	default:
+		return ""
+	}
+}
+
+// Page is implemented by the responses of paginated endpoints.
+type Page interface {
+	Pagination() PageInfo
+	// Len returns the number of records of the page.
+	Len() int
+}
+
+type pageOptions url.Values
+
+func (o pageOptions) Params() url.Values {
+	return url.Values(o)
+}
+
+// FetchAll requests endpoint and follows the returned page info until the result set is exhausted.
+// Every page is decoded into a value created by newPage and passed to handle.
+// It fails with ErrPagingStalled instead of requesting the same page again.
+func (c *Client) FetchAll(ctx context.Context, endpoint string, options FetchOptions, newPage func() Page, handle func(Page)) error {
+	params := url.Values{}
+
+	if options != nil {
+		for k, v := range options.Params() {
+			params[k] = v
+		}
+	}
+
+	if c.PageSize > 0 {
+		params.Set("count", strconv.Itoa(c.PageSize))
+	}
+
+	seen := map[string]bool{}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		resp, err := c.Fetch(ctx, endpoint, pageOptions(params), newPage())
+
+		if err != nil {
+			return err
+		}
+
+		p := resp.(Page)
+		handle(p)
+
+		cursor := p.Pagination().next(params)
+
+		switch {
+		case cursor == "":
+			return nil
+		case p.Len() == 0:
+			return fmt.Errorf("%w: empty page of %s", ErrPagingStalled, endpoint)
+		case seen[cursor]:
+			return fmt.Errorf("%w: repeated cursor %s of %s", ErrPagingStalled, cursor, endpoint)
+		}
+
+		seen[cursor] = true
+	}
+}
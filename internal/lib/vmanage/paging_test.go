@@ -0,0 +1,83 @@
+package vmanage_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// noAuth sends requests without credentials.
+type noAuth struct{}
+
+func (noAuth) Login(ctx context.Context, c *vmanage.Client) error { return nil }
+func (noAuth) Logout(c *vmanage.Client) error                     { return nil }
+func (noAuth) Authenticate(r *resty.Request) bool                 { return true }
+func (noAuth) ID() string                                         { return "" }
+
+// newPagingClient returns a client of a server responding with the pages returned by page for the n-th request.
+func newPagingClient(t *testing.T, page func(n int) string) (*vmanage.Client, *int) {
+	t.Helper()
+
+	var mu sync.Mutex
+	requests := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		n := requests
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, page(n))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := vmanage.NewClient(srv.URL, "", "")
+	c.Auth = noAuth{}
+	c.Retries = 0
+
+	return c, &requests
+}
+
+func TestFetchAllRepeatedCursor(t *testing.T) {
+	c, requests := newPagingClient(t, func(n int) string {
+		return `{"data":[{"ifname":"ge0/0"}],"pageInfo":{"endId":"7","moreEntries":true,"count":1}}`
+	})
+
+	_, err := c.DeviceStateInterface(context.Background())
+
+	if !errors.Is(err, vmanage.ErrPagingStalled) {
+		t.Fatalf("Expected ErrPagingStalled, got %v", err)
+	}
+
+	if *requests != 2 {
+		t.Errorf("Expected 2 requests, got %d", *requests)
+	}
+}
+
+func TestFetchAllEmptyPage(t *testing.T) {
+	c, requests := newPagingClient(t, func(n int) string {
+		if n > 1 {
+			return `{"data":[],"pageInfo":{"endId":"` + strconv.Itoa(n) + `","moreEntries":true,"count":0}}`
+		}
+
+		return `{"data":[{"ifname":"ge0/0"}],"pageInfo":{"endId":"1","moreEntries":true,"count":1}}`
+	})
+
+	_, err := c.DeviceStateInterface(context.Background())
+
+	if !errors.Is(err, vmanage.ErrPagingStalled) {
+		t.Fatalf("Expected ErrPagingStalled, got %v", err)
+	}
+
+	if *requests != 2 {
+		t.Errorf("Expected 2 requests, got %d", *requests)
+	}
+}
@@ -0,0 +1,342 @@
+package vmanage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// redacted replaces secrets in recordings.
+const redacted = "REDACTED"
+
+// secretEndpoints respond with a secret as body.
+var secretEndpoints = map[string]bool{
+	"/dataservice/client/token": true,
+}
+
+// secretFields are redacted from JSON bodies of recorded requests and responses.
+var secretFields = map[string]bool{
+	"token":    true,
+	"csrf":     true,
+	"refresh":  true,
+	"password": true,
+	// issued for tenants in multi-tenant mode
+	"VSessionId": true,
+}
+
+// secretFormFields are redacted from form bodies of recorded requests.
+var secretFormFields = map[string]bool{
+	"j_password": true,
+}
+
+// secretHeaders carry credentials in requests.
+var secretHeaders = []string{"Authorization", "Cookie", "X-XSRF-TOKEN", "VSessionId"}
+
+// Recording is a request to vManage and its response, stored by RecordDir. The response is served by ReplayDir.
+// Body holds JSON responses as is, other responses are kept as Text.
+type Recording struct {
+	Method     string           `json:"method"`
+	URL        string           `json:"url"`
+	Request    *RecordedRequest `json:"request,omitempty"`
+	StatusCode int              `json:"status_code"`
+	Header     http.Header      `json:"header"`
+	Body       json.RawMessage  `json:"body,omitempty"`
+	Text       string           `json:"text,omitempty"`
+}
+
+// RecordedRequest holds headers and body of a recorded request.
+type RecordedRequest struct {
+	Header http.Header     `json:"header"`
+	Body   json.RawMessage `json:"body,omitempty"`
+	Text   string          `json:"text,omitempty"`
+}
+
+// recordTransport writes every request and response passed to next to dir, with cookies,
+// tokens and passwords redacted.
+type recordTransport struct {
+	dir  string
+	next http.RoundTripper
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		reqBody = b
+		req.Body = io.NopCloser(bytes.NewReader(b))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := t.write(req, reqBody, resp, body); err != nil {
+		return nil, fmt.Errorf("Error recording %s: %w", req.URL.Path, err)
+	}
+
+	return resp, nil
+}
+
+func (t *recordTransport) write(req *http.Request, reqBody []byte, resp *http.Response, body []byte) error {
+	rec := Recording{
+		Method:     req.Method,
+		URL:        recordingURL(req),
+		Request:    recordRequest(req, reqBody),
+		StatusCode: resp.StatusCode,
+		Header:     redactHeader(resp.Header),
+	}
+
+	switch {
+	case secretEndpoints[req.URL.Path]:
+		rec.Text = redacted
+	case json.Valid(body):
+		rec.Body = redactJSON(body)
+	default:
+		rec.Text = string(body)
+	}
+
+	b, err := json.MarshalIndent(rec, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	dir := recordingDir(t.dir, req)
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, recordingFile(req)), b, 0o644)
+}
+
+// replayTransport serves the responses recorded in dir instead of sending requests.
+// Requests without recording are answered with 404.
+type replayTransport struct {
+	dir string
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	b, err := os.ReadFile(filepath.Join(recordingDir(t.dir, req), recordingFile(req)))
+
+	if os.IsNotExist(err) {
+		return &http.Response{
+			Status:     "404 Not Found",
+			StatusCode: http.StatusNotFound,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{"Content-Type": {"text/plain"}},
+			Body:       io.NopCloser(strings.NewReader("No recording of " + recordingKey(req))),
+			Request:    req,
+		}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var rec Recording
+
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return nil, fmt.Errorf("Error parsing recording of %s: %w", req.URL.Path, err)
+	}
+
+	body := []byte(rec.Text)
+
+	if len(rec.Body) > 0 {
+		body = rec.Body
+	}
+
+	header := rec.Header.Clone()
+
+	if header == nil {
+		header = http.Header{}
+	}
+
+	header.Del("Content-Length")
+	header.Del("Content-Encoding")
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.StatusCode, http.StatusText(rec.StatusCode)),
+		StatusCode:    rec.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// recordingURL returns path and query of a request without the random nocache parameter of the logout.
+func recordingURL(req *http.Request) string {
+	q := req.URL.Query()
+	q.Del("nocache")
+
+	if len(q) == 0 {
+		return req.URL.Path
+	}
+
+	return req.URL.Path + "?" + q.Encode()
+}
+
+// recordingKey identifies the response to a request by method, path and query.
+func recordingKey(req *http.Request) string {
+	return req.Method + " " + recordingURL(req)
+}
+
+// recordingDir returns the directory of the recordings of a request: dir, or a subdirectory
+// per tenant for requests of tenant clients, as tenants share the endpoints.
+func recordingDir(dir string, req *http.Request) string {
+	if tenant, ok := req.Context().Value(tenantKey{}).(string); ok && tenant != "" {
+		return filepath.Join(dir, "tenant-"+strings.ReplaceAll(tenant, string(filepath.Separator), "_"))
+	}
+
+	return dir
+}
+
+// recordingFile returns the file name of the recording of a request: the path followed
+// by a hash of its key, as the same endpoint is requested with different parameters.
+func recordingFile(req *http.Request) string {
+	sum := sha256.Sum256([]byte(recordingKey(req)))
+	name := strings.ReplaceAll(strings.Trim(req.URL.Path, "/"), "/", "_")
+
+	return fmt.Sprintf("%s-%s.json", name, hex.EncodeToString(sum[:4]))
+}
+
+// recordRequest returns headers and body of req with credentials redacted.
+func recordRequest(req *http.Request, body []byte) *RecordedRequest {
+	rec := &RecordedRequest{Header: req.Header.Clone()}
+
+	for _, h := range secretHeaders {
+		if rec.Header.Get(h) != "" {
+			rec.Header.Set(h, redacted)
+		}
+	}
+
+	switch {
+	case len(body) == 0:
+	case json.Valid(body):
+		rec.Body = redactJSON(body)
+	case strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded"):
+		rec.Text = redactForm(body)
+	default:
+		rec.Text = string(body)
+	}
+
+	return rec
+}
+
+// redactForm replaces the values of secretFormFields in a form body.
+func redactForm(body []byte) string {
+	form, err := url.ParseQuery(string(body))
+
+	if err != nil {
+		return redacted
+	}
+
+	for k := range form {
+		if secretFormFields[k] {
+			form.Set(k, redacted)
+		}
+	}
+
+	return form.Encode()
+}
+
+// redactHeader replaces the values of cookies set by vManage.
+func redactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	var cookies []string
+
+	for _, c := range (&http.Response{Header: h}).Cookies() {
+		c.Value = redacted
+		cookies = append(cookies, c.String())
+	}
+
+	h.Del("Set-Cookie")
+
+	for _, c := range cookies {
+		h.Add("Set-Cookie", c)
+	}
+
+	return h
+}
+
+// redactJSON replaces the values of secretFields anywhere in a JSON document.
+func redactJSON(body []byte) []byte {
+	var v interface{}
+
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+
+	if !redactValue(v) {
+		return body
+	}
+
+	b, err := json.Marshal(v)
+
+	if err != nil {
+		return body
+	}
+
+	return b
+}
+
+// redactValue redacts v in place and reports whether it contained secrets.
+func redactValue(v interface{}) bool {
+	found := false
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if secretFields[k] {
+				v[k] = redacted
+				found = true
+			} else if redactValue(e) {
+				found = true
+			}
+		}
+	case []interface{}:
+		for _, e := range v {
+			if redactValue(e) {
+				found = true
+			}
+		}
+	}
+
+	return found
+}
@@ -0,0 +1,88 @@
+package vmanage_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage"
+	"github.com/zebbra/vmanage-exporter/internal/lib/vmanage/vmanagetest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	c, srv := newClient(t)
+	auth := &vmanage.SessionAuth{}
+	c.Auth = auth
+	c.RecordDir = dir
+	ctx := context.Background()
+
+	recorded, err := c.Device(ctx)
+
+	if err != nil {
+		t.Fatalf("Error fetching devices: %s", err)
+	}
+
+	session, token := auth.Session.Value, auth.Token
+
+	if err := c.Logout(); err != nil {
+		t.Fatalf("Logout failed: %s", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+
+	if len(files) == 0 {
+		t.Fatal("Expected recordings")
+	}
+
+	for _, f := range files {
+		b, _ := os.ReadFile(f)
+
+		if strings.Contains(string(b), session) || strings.Contains(string(b), token) || strings.Contains(string(b), vmanagetest.Password) {
+			t.Errorf("Expected secrets to be redacted in %s", f)
+		}
+	}
+
+	login, _ := filepath.Glob(filepath.Join(dir, "j_security_check-*.json"))
+
+	if len(login) != 1 {
+		t.Fatalf("Expected recording of login, got %v", login)
+	}
+
+	var rec vmanage.Recording
+	b, _ := os.ReadFile(login[0])
+
+	if err := json.Unmarshal(b, &rec); err != nil {
+		t.Fatalf("Error parsing recording: %s", err)
+	}
+
+	if rec.Request == nil || !strings.Contains(rec.Request.Text, "j_username="+vmanagetest.Username) || !strings.Contains(rec.Request.Text, "j_password=REDACTED") {
+		t.Errorf("Expected login request with redacted password, got %+v", rec.Request)
+	}
+
+	srv.Close()
+
+	r := vmanage.NewClient(srv.URL, "", "")
+	r.ReplayDir = dir
+	r.Retries = 0
+
+	replayed, err := r.Device(ctx)
+
+	if err != nil {
+		t.Fatalf("Error replaying devices: %s", err)
+	}
+
+	if len(replayed) != len(recorded) || replayed[0].Hostname != recorded[0].Hostname {
+		t.Errorf("Expected replayed devices %+v, got %+v", recorded, replayed)
+	}
+
+	if err := r.Logout(); err != nil {
+		t.Errorf("Logout failed: %s", err)
+	}
+
+	if _, err := r.DeviceCounter(ctx); err == nil {
+		t.Error("Expected request without recording to fail")
+	}
+}
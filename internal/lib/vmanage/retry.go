@@ -0,0 +1,87 @@
+package vmanage
+
+import (
+	"context"
+	"errors"
+	"github.com/go-resty/resty/v2"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// StatusError is returned for error responses of vManage.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return e.Status + ": " + e.Body
+}
+
+// retryable reports whether err is caused by vManage being unavailable, so the request may succeed later:
+// network errors, throttling and server errors.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, ErrThrottled) {
+		return true
+	}
+
+	var statusErr *StatusError
+
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// backoff returns the time to wait before retry number attempt, starting at 0. It honours the
+// Retry-After header of resp, otherwise it doubles RetryWait with every attempt and adds jitter.
+// The result never exceeds RetryMaxWait.
+func (c *Client) backoff(attempt int, resp *resty.Response) time.Duration {
+	wait := c.RetryWait << attempt
+
+	if wait <= 0 || wait > c.RetryMaxWait {
+		wait = c.RetryMaxWait
+	}
+
+	// full jitter within the upper half, so retries of concurrent requests spread
+	wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header().Get("Retry-After")); ok {
+			wait = d
+		}
+	}
+
+	if wait > c.RetryMaxWait {
+		wait = c.RetryMaxWait
+	}
+
+	return wait
+}
+
+// retryAfter parses the value of a Retry-After header, which is either seconds or a date.
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,110 @@
+package vmanage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// tenantKey holds the tenant id in the context of requests, so recordings of tenants are kept apart.
+type tenantKey struct{}
+
+// ForTenant returns a client for the tenant with id of a multi-tenant vManage, which sends requests
+// with the VSessionId of the tenant. It shares login, connections, rate limit and circuit breaker
+// with c, which has to log in as provider.
+func (c *Client) ForTenant(id string) *Client {
+	return c.view(id, c.OnRequest, c.OnRetry)
+}
+
+// WithObserver returns a client for the same tenant as c, which shares login, connections, rate limit
+// and circuit breaker with c, but passes the outcome of its requests to onRequest and does not call OnRetry.
+// Logins are still observed by c.
+func (c *Client) WithObserver(onRequest func(method string, endpoint string, code int, duration time.Duration, err error)) *Client {
+	return c.view(c.tenantID, onRequest, nil)
+}
+
+func (c *Client) view(tenantID string, onRequest func(string, string, int, time.Duration, error), onRetry func(string, error)) *Client {
+	p := c.shared()
+
+	return &Client{
+		BaseURL:      p.BaseURL,
+		Endpoints:    p.Endpoints,
+		Username:     p.Username,
+		PageSize:     p.PageSize,
+		Retries:      p.Retries,
+		RetryWait:    p.RetryWait,
+		RetryMaxWait: p.RetryMaxWait,
+		Breaker:      p.Breaker,
+		OnRetry:      onRetry,
+		OnRequest:    onRequest,
+		provider:     p,
+		tenantID:     tenantID,
+	}
+}
+
+// TenantID returns the tenant of a client returned by ForTenant, otherwise an empty string.
+func (c *Client) TenantID() string {
+	return c.tenantID
+}
+
+// shared returns the client holding login and connections: the provider of a tenant client or c itself.
+func (c *Client) shared() *Client {
+	if c.provider != nil {
+		return c.provider
+	}
+
+	return c
+}
+
+// vsession returns the VSessionId of the tenant issued for the provider credentials identified by authID.
+// After every login of the provider a new one is requested.
+func (c *Client) vsession(ctx context.Context, authID string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.vsessionID != "" && c.vsessionAuth == authID {
+		return c.vsessionID, nil
+	}
+
+	endpoint := "/dataservice/tenant/" + url.PathEscape(c.tenantID) + "/vsessionid"
+
+	p := c.provider
+	p.mu.Lock()
+	r, err := p.request(ctx)
+	id := p.authenticator().ID()
+	u := p.url(endpoint)
+	p.mu.Unlock()
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := p.wait(ctx); err != nil {
+		return "", err
+	}
+
+	startTime := time.Now()
+	resp, err := r.SetContext(ctx).Post(u)
+	err = checkResponse(resp, err)
+	c.observe(http.MethodPost, endpoint, resp, startTime, err)
+
+	if err != nil {
+		return "", err
+	}
+
+	var res struct {
+		VSessionID string `json:"VSessionId"`
+	}
+
+	if err := json.Unmarshal(resp.Body(), &res); err != nil || res.VSessionID == "" {
+		return "", errors.New("No VSessionId in response")
+	}
+
+	c.vsessionID = res.VSessionID
+	c.vsessionAuth = id
+
+	return c.vsessionID, nil
+}
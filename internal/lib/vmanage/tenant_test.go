@@ -0,0 +1,85 @@
+package vmanage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTenants(t *testing.T) {
+	c, srv := newClient(t)
+	ctx := context.Background()
+
+	tenants, err := c.Tenant(ctx)
+
+	if err != nil {
+		t.Fatalf("Error fetching tenants: %s", err)
+	}
+
+	if len(tenants) != 2 {
+		t.Fatalf("Expected 2 tenants, got %d", len(tenants))
+	}
+
+	acme := c.ForTenant(tenants[0].TenantID)
+	devices, err := acme.Device(ctx)
+
+	if err != nil {
+		t.Fatalf("Error fetching devices of tenant: %s", err)
+	}
+
+	if len(devices) != 1 || devices[0].Hostname != "edge-zrh-1" {
+		t.Errorf("Expected device edge-zrh-1 of tenant acme, got %+v", devices)
+	}
+
+	srv.ExpireSessions()
+
+	if _, err := acme.Device(ctx); err != nil {
+		t.Fatalf("Error fetching devices of tenant with expired session: %s", err)
+	}
+
+	if n := srv.Requests("/dataservice/tenant/t-acme/vsessionid"); n != 2 {
+		t.Errorf("Expected a new VSessionId after the session expired, got %d requests", n)
+	}
+
+	if err := acme.Logout(); err != nil {
+		t.Fatalf("Logout failed: %s", err)
+	}
+
+	// the provider stays logged in
+	if _, err := c.Device(ctx); err != nil {
+		t.Fatalf("Error fetching devices of provider: %s", err)
+	}
+
+	if n := srv.Logins(); n != 1 {
+		t.Errorf("Expected 1 login, got %d", n)
+	}
+}
+
+func TestWithObserver(t *testing.T) {
+	c, _ := newClient(t)
+	ctx := context.Background()
+
+	if err := c.Login(ctx); err != nil {
+		t.Fatalf("Login failed: %s", err)
+	}
+
+	var requests, observed int
+	c.OnRequest = func(string, string, int, time.Duration, error) { requests++ }
+	v := c.WithObserver(func(string, string, int, time.Duration, error) { observed++ })
+
+	if _, err := v.Device(ctx); err != nil {
+		t.Fatalf("Error fetching devices: %s", err)
+	}
+
+	if requests != 0 || observed != 1 {
+		t.Errorf("Expected the request to be observed by the view only, got %d and %d", requests, observed)
+	}
+
+	if err := v.Logout(); err != nil {
+		t.Fatalf("Logout failed: %s", err)
+	}
+
+	if _, err := c.Device(ctx); err != nil || requests != 1 {
+		t.Errorf("Expected c to stay logged in, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package vmanage
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+)
+
+func (c *Client) Alarm(ctx context.Context, options *AlarmListOptions) ([]Alarm, error) {
+	resp, err := c.Fetch(
+		ctx,
+		"/dataservice/alarms",
+		options,
+		&AlarmList{},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	list := resp.(*AlarmList)
+	return list.Data, nil
+}
+
+type Alarm struct {
+	UUID            string `json:"uuid"`
+	RuleNameDisplay string `json:"rule_name_display"`
+	Severity        string `json:"severity"`
+	Type            string `json:"type"`
+	Component       string `json:"component"`
+	Message         string `json:"message"`
+	Active          bool   `json:"active"`
+	Acknowledged    bool   `json:"acknowledged"`
+	EntryTime       int64  `json:"entry_time"`
+	SystemIP        string `json:"system_ip,omitempty"`
+	HostName        string `json:"host_name,omitempty"`
+	SiteID          string `json:"site_id,omitempty"`
+	Devices         []struct {
+		SystemIP string `json:"system-ip"`
+	} `json:"devices"`
+}
+
+type AlarmList struct {
+	Data []Alarm `json:"data"`
+}
+
+// AlarmListOptions are translated into the query language of the alarms API.
+type AlarmListOptions struct {
+	Active     bool
+	LastNHours int
+}
+
+type alarmQueryRule struct {
+	Value    []string `json:"value"`
+	Field    string   `json:"field"`
+	Type     string   `json:"type"`
+	Operator string   `json:"operator"`
+}
+
+type alarmQuery struct {
+	Query struct {
+		Condition string           `json:"condition"`
+		Rules     []alarmQueryRule `json:"rules"`
+	} `json:"query"`
+}
+
+func (o *AlarmListOptions) Params() url.Values {
+	q := alarmQuery{}
+	q.Query.Condition = "AND"
+
+	if o.LastNHours > 0 {
+		q.Query.Rules = append(q.Query.Rules, alarmQueryRule{
+			Value:    []string{strconv.Itoa(o.LastNHours)},
+			Field:    "entry_time",
+			Type:     "date",
+			Operator: "last_n_hours",
+		})
+	}
+
+	if o.Active {
+		q.Query.Rules = append(q.Query.Rules, alarmQueryRule{
+			Value:    []string{"true"},
+			Field:    "active",
+			Type:     "string",
+			Operator: "equal",
+		})
+	}
+
+	if len(q.Query.Rules) == 0 {
+		return url.Values{}
+	}
+
+	b, _ := json.Marshal(q)
+	return url.Values{"query": {string(b)}}
+}
@@ -0,0 +1,72 @@
+package vmanage
+
+import (
+	"context"
+	"time"
+)
+
+// CertificateController returns the certificates of the controllers.
+func (c *Client) CertificateController(ctx context.Context) ([]CertificateRecord, error) {
+	resp, err := c.Fetch(
+		ctx,
+		"/dataservice/certificate/vsmart/list",
+		nil,
+		&CertificateRecordList{},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	list := resp.(*CertificateRecordList)
+	return list.Data, nil
+}
+
+// CertificateRecord returns the certificates of the WAN edge devices.
+func (c *Client) CertificateRecord(ctx context.Context) ([]CertificateRecord, error) {
+	resp, err := c.Fetch(
+		ctx,
+		"/dataservice/certificate/record",
+		nil,
+		&CertificateRecordList{},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	list := resp.(*CertificateRecordList)
+	return list.Data, nil
+}
+
+type CertificateRecord struct {
+	UUID               string `json:"uuid"`
+	SystemIP           string `json:"system-ip"`
+	HostName           string `json:"host-name"`
+	DeviceType         string `json:"deviceType"`
+	ChassisNumber      string `json:"chassisNumber"`
+	SerialNumber       string `json:"serialNumber"`
+	Validity           string `json:"validity"`
+	State              string `json:"state"`
+	ExpirationDate     string `json:"expirationDate"`
+	ExpirationDateLong int64  `json:"expirationDateLong"`
+}
+
+// Expiry returns the expiration date of the certificate and whether it is known.
+func (r *CertificateRecord) Expiry() (time.Time, bool) {
+	if r.ExpirationDateLong > 0 {
+		return time.UnixMilli(r.ExpirationDateLong), true
+	}
+
+	for _, layout := range []string{"02 Jan 2006 3:04:05 PM MST", "02 Jan 2006 3:04:05 PM", time.RFC3339} {
+		if t, err := time.Parse(layout, r.ExpirationDate); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+type CertificateRecordList struct {
+	Data []CertificateRecord `json:"data"`
+}
@@ -66,6 +66,10 @@ func (d *Device) IsReachable() bool {
 	return false
 }
 
+func (d *Device) HasValidCertificate() bool {
+	return d.CertificateValidity == "Valid"
+}
+
 type DeviceList struct {
 	Data []Device `json:"data"`
 }
@@ -0,0 +1,66 @@
+package vmanage
+
+import (
+	"context"
+	"github.com/google/go-querystring/query"
+	"net/url"
+)
+
+func (c *Client) DeviceAppRouteStatistics(ctx context.Context, options *DeviceAppRouteStatisticsListOptions) ([]DeviceAppRouteStatistics, error) {
+	resp, err := c.Fetch(
+		ctx,
+		"/dataservice/device/app-route/statistics",
+		options,
+		&DeviceAppRouteStatisticsList{},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	list := resp.(*DeviceAppRouteStatisticsList)
+	return list.Data, nil
+}
+
+// DeviceAppRouteStatistics holds the measurements of a tunnel within one bucket of the
+// application-aware routing poll interval. Mean values are calculated over all buckets.
+type DeviceAppRouteStatistics struct {
+	VdeviceName     string  `json:"vdevice-name"`
+	VdeviceHostName string  `json:"vdevice-host-name"`
+	VdeviceDataKey  string  `json:"vdevice-dataKey"`
+	SrcIP           string  `json:"src-ip"`
+	DstIP           string  `json:"dst-ip"`
+	SrcPort         int     `json:"src-port"`
+	DstPort         int     `json:"dst-port"`
+	Proto           string  `json:"proto"`
+	LocalColor      string  `json:"local-color"`
+	RemoteColor     string  `json:"remote-color"`
+	RemoteSystemIP  string  `json:"remote-system-ip"`
+	SLAClassIndex   string  `json:"sla-class-index"`
+	Index           int     `json:"index"`
+	MeanLoss        float64 `json:"mean-loss"`
+	MeanLatency     float64 `json:"mean-latency"`
+	MeanJitter      float64 `json:"mean-jitter"`
+	TotalPackets    int     `json:"total-packets"`
+	Loss            float64 `json:"loss"`
+	AverageLatency  float64 `json:"average-latency"`
+	AverageJitter   float64 `json:"average-jitter"`
+	TxPkts          int     `json:"tx-pkts"`
+	RxPkts          int     `json:"rx-pkts"`
+	Lastupdated     int64   `json:"lastupdated"`
+}
+
+type DeviceAppRouteStatisticsList struct {
+	Data []DeviceAppRouteStatistics `json:"data"`
+}
+
+type DeviceAppRouteStatisticsListOptions struct {
+	DeviceID    string `url:"deviceId,omitempty"`
+	RemoteColor string `url:"remote-color,omitempty"`
+	LocalColor  string `url:"local-color,omitempty"`
+}
+
+func (o *DeviceAppRouteStatisticsListOptions) Params() url.Values {
+	v, _ := query.Values(o)
+	return v
+}
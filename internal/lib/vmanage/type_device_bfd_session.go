@@ -0,0 +1,90 @@
+package vmanage
+
+import (
+	"context"
+	"github.com/google/go-querystring/query"
+	"net/url"
+)
+
+func (c *Client) DeviceBFDSessions(ctx context.Context, options *DeviceBFDSessionListOptions) ([]DeviceBFDSession, error) {
+	resp, err := c.Fetch(
+		ctx,
+		"/dataservice/device/bfd/sessions",
+		options,
+		&DeviceBFDSessionList{},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	list := resp.(*DeviceBFDSessionList)
+	return list.Data, nil
+}
+
+// DeviceStateBFDSessions returns the BFD sessions of all devices from the state data API.
+func (c *Client) DeviceStateBFDSessions(ctx context.Context) ([]DeviceBFDSession, error) {
+	var sessions []DeviceBFDSession
+
+	err := c.FetchAll(
+		ctx,
+		"/dataservice/data/device/state/BFDSessions",
+		nil,
+		func() Page { return &DeviceBFDSessionList{} },
+		func(p Page) { sessions = append(sessions, p.(*DeviceBFDSessionList).Data...) },
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+type DeviceBFDSession struct {
+	VdeviceName      string `json:"vdevice-name"`
+	VdeviceHostName  string `json:"vdevice-host-name"`
+	VdeviceDataKey   string `json:"vdevice-dataKey"`
+	SystemIP         string `json:"system-ip"`
+	SiteID           string `json:"site-id"`
+	State            string `json:"state"`
+	SrcIP            string `json:"src-ip"`
+	DstIP            string `json:"dst-ip"`
+	SrcPort          int    `json:"src-port"`
+	DstPort          int    `json:"dst-port"`
+	LocalColor       string `json:"local-color"`
+	Color            string `json:"color"`
+	Proto            string `json:"proto"`
+	Transitions      int    `json:"transitions"`
+	TxInterval       int    `json:"tx-interval"`
+	DetectMultiplier int    `json:"detect-multiplier"`
+	Uptime           string `json:"uptime,omitempty"`
+	UptimeDate       int64  `json:"uptime-date,omitempty"`
+	Lastupdated      int64  `json:"lastupdated"`
+}
+
+func (s *DeviceBFDSession) IsUp() bool {
+	return s.State == "up"
+}
+
+type DeviceBFDSessionList struct {
+	Data     []DeviceBFDSession `json:"data"`
+	PageInfo PageInfo           `json:"pageInfo"`
+}
+
+func (l *DeviceBFDSessionList) Pagination() PageInfo {
+	return l.PageInfo
+}
+
+func (l *DeviceBFDSessionList) Len() int {
+	return len(l.Data)
+}
+
+type DeviceBFDSessionListOptions struct {
+	DeviceID string `url:"deviceId,omitempty"`
+}
+
+func (o *DeviceBFDSessionListOptions) Params() url.Values {
+	v, _ := query.Values(o)
+	return v
+}
@@ -0,0 +1,107 @@
+package vmanage
+
+import (
+	"context"
+	"github.com/google/go-querystring/query"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+func (c *Client) DeviceHardwareEnvironment(ctx context.Context, options *DeviceHardwareEnvironmentListOptions) ([]DeviceHardwareEnvironment, error) {
+	resp, err := c.Fetch(
+		ctx,
+		"/dataservice/device/hardware/environment",
+		options,
+		&DeviceHardwareEnvironmentList{},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	list := resp.(*DeviceHardwareEnvironmentList)
+	return list.Data, nil
+}
+
+// DeviceStateHardwareEnvironment returns the hardware environment of all devices from the state data API.
+func (c *Client) DeviceStateHardwareEnvironment(ctx context.Context) ([]DeviceHardwareEnvironment, error) {
+	var environment []DeviceHardwareEnvironment
+
+	err := c.FetchAll(
+		ctx,
+		"/dataservice/data/device/state/HardwareEnvironment",
+		nil,
+		func() Page { return &DeviceHardwareEnvironmentList{} },
+		func(p Page) { environment = append(environment, p.(*DeviceHardwareEnvironmentList).Data...) },
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return environment, nil
+}
+
+type DeviceHardwareEnvironment struct {
+	VdeviceName     string `json:"vdevice-name"`
+	VdeviceHostName string `json:"vdevice-host-name"`
+	VdeviceDataKey  string `json:"vdevice-dataKey"`
+	HwClass         string `json:"hw-class"`
+	HwItem          string `json:"hw-item"`
+	HwDevIndex      int    `json:"hw-dev-index"`
+	Status          string `json:"status"`
+	Measurement     string `json:"measurement"`
+	Lastupdated     int64  `json:"lastupdated"`
+}
+
+func (e *DeviceHardwareEnvironment) IsOK() bool {
+	return strings.EqualFold(e.Status, "OK")
+}
+
+func (e *DeviceHardwareEnvironment) IsTemperature() bool {
+	return strings.Contains(strings.ToLower(e.HwClass), "temperature")
+}
+
+func (e *DeviceHardwareEnvironment) IsFan() bool {
+	return strings.Contains(strings.ToLower(e.HwClass), "fan")
+}
+
+func (e *DeviceHardwareEnvironment) IsPowerSupply() bool {
+	c := strings.ToLower(e.HwClass)
+	return strings.Contains(c, "power") || strings.Contains(c, "pem")
+}
+
+// Temperature parses measurements like "38 degrees C".
+func (e *DeviceHardwareEnvironment) Temperature() (float64, bool) {
+	fields := strings.Fields(e.Measurement)
+
+	if len(fields) == 0 {
+		return 0, false
+	}
+
+	t, err := strconv.ParseFloat(fields[0], 64)
+	return t, err == nil
+}
+
+type DeviceHardwareEnvironmentList struct {
+	Data     []DeviceHardwareEnvironment `json:"data"`
+	PageInfo PageInfo                    `json:"pageInfo"`
+}
+
+func (l *DeviceHardwareEnvironmentList) Pagination() PageInfo {
+	return l.PageInfo
+}
+
+func (l *DeviceHardwareEnvironmentList) Len() int {
+	return len(l.Data)
+}
+
+type DeviceHardwareEnvironmentListOptions struct {
+	DeviceID string `url:"deviceId,omitempty"`
+}
+
+func (o *DeviceHardwareEnvironmentListOptions) Params() url.Values {
+	v, _ := query.Values(o)
+	return v
+}
@@ -0,0 +1,35 @@
+package vmanage
+
+import "context"
+
+// DeviceStateInterface returns the interfaces of all devices from the state data API.
+func (c *Client) DeviceStateInterface(ctx context.Context) ([]DeviceInterface, error) {
+	var interfaces []DeviceInterface
+
+	err := c.FetchAll(
+		ctx,
+		"/dataservice/data/device/state/Interface",
+		nil,
+		func() Page { return &DeviceStateInterfaceList{} },
+		func(p Page) { interfaces = append(interfaces, p.(*DeviceStateInterfaceList).Data...) },
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return interfaces, nil
+}
+
+type DeviceStateInterfaceList struct {
+	Data     []DeviceInterface `json:"data"`
+	PageInfo PageInfo          `json:"pageInfo"`
+}
+
+func (l *DeviceStateInterfaceList) Pagination() PageInfo {
+	return l.PageInfo
+}
+
+func (l *DeviceStateInterfaceList) Len() int {
+	return len(l.Data)
+}
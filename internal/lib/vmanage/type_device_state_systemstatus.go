@@ -3,19 +3,21 @@ package vmanage
 import "context"
 
 func (c *Client) DeviceStateSystemStatus(ctx context.Context) ([]DeviceStateSystemStatus, error) {
-	resp, err := c.Fetch(
+	var status []DeviceStateSystemStatus
+
+	err := c.FetchAll(
 		ctx,
 		"/dataservice/data/device/state/SystemStatus",
 		nil,
-		&DeviceStateSystemStatusList{},
+		func() Page { return &DeviceStateSystemStatusList{} },
+		func(p Page) { status = append(status, p.(*DeviceStateSystemStatusList).Data...) },
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	list := resp.(*DeviceStateSystemStatusList)
-	return list.Data, nil
+	return status, nil
 }
 
 type DeviceStateSystemStatus struct {
@@ -47,8 +49,66 @@ type DeviceStateSystemStatus struct {
 	LoghostStatus           string `json:"loghost_status"`
 	UptimeDate              int64  `json:"uptime-date"`
 	VmanageStorageDiskMount string `json:"vmanage-storage-disk-mount,omitempty"`
+	MemUsed                 string `json:"mem_used,omitempty"`
+	MemFree                 string `json:"mem_free,omitempty"`
+	MemTotal                string `json:"mem_total,omitempty"`
+	MemBuffers              string `json:"mem_buffers,omitempty"`
+	MemCached               string `json:"mem_cached,omitempty"`
+	CPUUser                 string `json:"cpu_user,omitempty"`
+	CPUSystem               string `json:"cpu_system,omitempty"`
+	CPUIdle                 string `json:"cpu_idle,omitempty"`
+	Min1Avg                 string `json:"min1_avg,omitempty"`
+	Min5Avg                 string `json:"min5_avg,omitempty"`
+	Min15Avg                string `json:"min15_avg,omitempty"`
+}
+
+// SystemStatus converts the state record to the format returned by the per device API.
+func (d *DeviceStateSystemStatus) SystemStatus() DeviceSystemStatus {
+	return DeviceSystemStatus{
+		MemUsed:           d.MemUsed,
+		MemFree:           d.MemFree,
+		MemTotal:          d.MemTotal,
+		MemBuffers:        d.MemBuffers,
+		MemCached:         d.MemCached,
+		CPUUser:           d.CPUUser,
+		CPUSystem:         d.CPUSystem,
+		CPUIdle:           d.CPUIdle,
+		Min1Avg:           d.Min1Avg,
+		Min5Avg:           d.Min5Avg,
+		Min15Avg:          d.Min15Avg,
+		BoardType:         d.BoardType,
+		VdeviceName:       d.VdeviceName,
+		TotalCPUCount:     d.TotalCPUCount,
+		FpCPUCount:        d.FpCPUCount,
+		StateDescription:  d.StateDescription,
+		Personality:       d.Personality,
+		DiskStatus:        d.DiskStatus,
+		State:             d.State,
+		LinuxCPUCount:     d.LinuxCPUCount,
+		TestbedMode:       d.TestbedMode,
+		ModelSku:          d.ModelSku,
+		Version:           d.Version,
+		TcpdCPUCount:      d.TcpdCPUCount,
+		VdeviceHostName:   d.VdeviceHostName,
+		VdeviceDataKey:    d.VdeviceDataKey,
+		BootloaderVersion: d.BootloaderVersion,
+		FipsMode:          d.FipsMode,
+		BuildNumber:       d.BuildNumber,
+		Lastupdated:       d.Lastupdated,
+		LoghostStatus:     d.LoghostStatus,
+		UptimeDate:        d.UptimeDate,
+	}
 }
 
 type DeviceStateSystemStatusList struct {
-	Data []DeviceStateSystemStatus `json:"data"`
+	Data     []DeviceStateSystemStatus `json:"data"`
+	PageInfo PageInfo                  `json:"pageInfo"`
+}
+
+func (l *DeviceStateSystemStatusList) Pagination() PageInfo {
+	return l.PageInfo
+}
+
+func (l *DeviceStateSystemStatusList) Len() int {
+	return len(l.Data)
 }
@@ -0,0 +1,32 @@
+package vmanage
+
+import "context"
+
+// Tenant lists the tenants of a multi-tenant vManage, which requires a login as provider.
+func (c *Client) Tenant(ctx context.Context) ([]Tenant, error) {
+	resp, err := c.Fetch(
+		ctx,
+		"/dataservice/tenant",
+		nil,
+		&TenantList{},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	list := resp.(*TenantList)
+	return list.Data, nil
+}
+
+type Tenant struct {
+	TenantID  string `json:"tenantId"`
+	Name      string `json:"name"`
+	OrgName   string `json:"orgName"`
+	SubDomain string `json:"subDomain"`
+	Desc      string `json:"desc"`
+}
+
+type TenantList struct {
+	Data []Tenant `json:"data"`
+}
@@ -0,0 +1,569 @@
+// Package vmanagetest provides a fake vManage serving recorded API responses for tests.
+package vmanagetest
+
+import (
+	"crypto/rand"
+	"embed"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	Username = "admin"
+	Password = "secret"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// routes maps the API endpoints to their fixture. State endpoints are paginated by the server.
+var routes = map[string]string{
+	"/dataservice/device":                                "device.json",
+	"/dataservice/device/counters":                       "device_counters.json",
+	"/dataservice/device/interface":                      "device_interface.json",
+	"/dataservice/device/interface/synced":               "device_interface.json",
+	"/dataservice/device/system/status":                  "device_system_status.json",
+	"/dataservice/device/system/synced/status":           "device_system_status.json",
+	"/dataservice/device/bfd/sessions":                   "device_bfd_sessions.json",
+	"/dataservice/device/hardware/environment":           "device_hardware_environment.json",
+	"/dataservice/device/app-route/statistics":           "device_app_route_statistics.json",
+	"/dataservice/alarms":                                "alarms.json",
+	"/dataservice/tenant":                                "tenant.json",
+	"/dataservice/certificate/vsmart/list":               "certificate_vsmart_list.json",
+	"/dataservice/certificate/record":                    "certificate_record.json",
+	"/dataservice/data/device/state/Interface":           "device_interface.json",
+	"/dataservice/data/device/state/SystemStatus":        "device_system_status.json",
+	"/dataservice/data/device/state/BFDSessions":         "device_bfd_sessions.json",
+	"/dataservice/data/device/state/HardwareEnvironment": "device_hardware_environment.json",
+}
+
+// TenantDevices maps the tenants listed by the server to the system ip of their device.
+var TenantDevices = map[string]string{
+	"t-acme":   "10.0.0.1",
+	"t-globex": "10.0.0.2",
+}
+
+// Server is a fake vManage. It accepts the login Username and Password and serves the fixtures
+// of the API endpoints used by the exporter to requests with a valid session and token or JWT.
+// Records of per device endpoints are filtered by the deviceId parameter, records of requests
+// with the VSessionId of a tenant by the device of the tenant.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	sessions  map[string]string
+	jwts      map[string]string
+	refreshes map[string]bool
+	vsessions map[string]string
+	noJWT     bool
+	down      bool
+	logins    int
+	refreshed int
+	requests  map[string]int
+	failures  map[string][]int
+	bodies    map[string]string
+}
+
+// NewServer starts a fake vManage, which has to be closed by the caller.
+func NewServer() *Server {
+	s := &Server{
+		sessions:  map[string]string{},
+		jwts:      map[string]string{},
+		refreshes: map[string]bool{},
+		vsessions: map[string]string{},
+		requests:  map[string]int{},
+		failures:  map[string][]int{},
+		bodies:    map[string]string{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/j_security_check", s.login)
+	mux.HandleFunc("/dataservice/client/token", s.token)
+	mux.HandleFunc("/logout", s.logout)
+	mux.HandleFunc("/jwt/login", s.jwtLogin)
+	mux.HandleFunc("/jwt/refresh", s.jwtRefresh)
+	mux.HandleFunc("/welcome.html", loginPage)
+	mux.HandleFunc("/dataservice/", s.data)
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		down := s.down
+		s.mu.Unlock()
+
+		if down {
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	}))
+	return s
+}
+
+// SetDown makes the server respond to all requests with 503 like a node of a cluster
+// whose application server is not running.
+func (s *Server) SetDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.down = down
+}
+
+// Fail makes the next requests to path respond with the given status codes, one per request.
+func (s *Server) Fail(path string, codes ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures[path] = append(s.failures[path], codes...)
+}
+
+// Respond replaces the fixture of path by body.
+func (s *Server) Respond(path string, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.bodies[path] = body
+}
+
+// DisableJWT makes the server respond to the JWT endpoints with 404 like versions before 20.12.
+func (s *Server) DisableJWT() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.noJWT = true
+}
+
+// ExpireSessions invalidates all sessions, JWTs and VSessionIds, as vManage does after a session timeout.
+// Refresh tokens remain valid.
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions = map[string]string{}
+	s.jwts = map[string]string{}
+	s.vsessions = map[string]string{}
+}
+
+// Sessions returns the number of sessions which were not logged out.
+func (s *Server) Sessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.sessions)
+}
+
+// Logins returns the number of successful logins, with form or JWT.
+func (s *Server) Logins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.logins
+}
+
+// Refreshes returns the number of JWTs issued for a refresh token.
+func (s *Server) Refreshes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.refreshed
+}
+
+// Requests returns the number of API requests to path, including failed ones.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[path]
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.FormValue("j_username") != Username || r.FormValue("j_password") != Password {
+		// vManage responds to failed logins with the login page
+		loginPage(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	id := randomID()
+	s.sessions[id] = ""
+	s.logins++
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: id, Path: "/"})
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.session(r)
+
+	if !ok {
+		http.Redirect(w, r, "/welcome.html", http.StatusFound)
+		return
+	}
+
+	s.mu.Lock()
+	token := randomID()
+	s.sessions[id] = token
+	s.mu.Unlock()
+
+	_, _ = w.Write([]byte(token))
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	if id, ok := s.session(r); ok {
+		s.mu.Lock()
+		delete(s.sessions, id)
+		s.mu.Unlock()
+	}
+
+	http.Redirect(w, r, "/welcome.html?nocache="+r.URL.Query().Get("nocache"), http.StatusFound)
+}
+
+func (s *Server) jwtLogin(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Duration int    `json:"duration"`
+	}
+
+	if s.jwtDisabled() {
+		http.NotFound(w, r)
+		return
+	}
+
+	if failure := s.failure(r.URL.Path); failure != 0 {
+		http.Error(w, http.StatusText(failure), failure)
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.Method != http.MethodPost {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if req.Username != Username || req.Password != Password {
+		http.Error(w, `{"error":"Invalid credentials"}`, http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	s.logins++
+	s.mu.Unlock()
+
+	s.issueJWT(w, randomID(), time.Duration(req.Duration)*time.Second)
+}
+
+func (s *Server) jwtRefresh(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Refresh string `json:"refresh"`
+	}
+
+	if s.jwtDisabled() {
+		http.NotFound(w, r)
+		return
+	}
+
+	if failure := s.failure(r.URL.Path); failure != 0 {
+		http.Error(w, http.StatusText(failure), failure)
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.Method != http.MethodPost {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	ok := s.refreshes[req.Refresh]
+
+	if ok {
+		s.refreshed++
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		http.Error(w, `{"error":"Invalid refresh token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	s.issueJWT(w, req.Refresh, 0)
+}
+
+// issueJWT responds with a new JWT valid for duration, by default 30 minutes.
+func (s *Server) issueJWT(w http.ResponseWriter, refresh string, duration time.Duration) {
+	if duration <= 0 {
+		duration = 30 * time.Minute
+	}
+
+	claims, _ := json.Marshal(map[string]interface{}{
+		"sub": Username,
+		"exp": time.Now().Add(duration).Unix(),
+		"jti": randomID(),
+	})
+
+	token := "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(claims) + ".c2ln"
+	csrf := randomID()
+
+	s.mu.Lock()
+	s.jwts[token] = csrf
+	s.refreshes[refresh] = true
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"token": token, "csrf": csrf, "refresh": refresh})
+}
+
+// failure returns the next status code set by Fail for path, 0 if there is none.
+func (s *Server) failure(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failures := s.failures[path]
+
+	if len(failures) == 0 {
+		return 0
+	}
+
+	s.failures[path] = failures[1:]
+	return failures[0]
+}
+
+func (s *Server) jwtDisabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.noJWT
+}
+
+// authorized checks the JWT or the session and token of a request.
+func (s *Server) authorized(r *http.Request) bool {
+	if token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); token != "" {
+		s.mu.Lock()
+		csrf, ok := s.jwts[token]
+		s.mu.Unlock()
+
+		return ok && csrf == r.Header.Get("X-XSRF-TOKEN")
+	}
+
+	id, ok := s.session(r)
+	return ok && s.sessionToken(id) == r.Header.Get("X-XSRF-TOKEN")
+}
+
+// vsession issues a VSessionId for the tenant in the path /dataservice/tenant/<id>/vsessionid.
+func (s *Server) vsession(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/dataservice/tenant/"), "/vsessionid")
+
+	if _, ok := TenantDevices[id]; !ok || r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+
+	vsessionID := randomID()
+
+	s.mu.Lock()
+	s.vsessions[vsessionID] = id
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"VSessionId": vsessionID})
+}
+
+func (s *Server) data(w http.ResponseWriter, r *http.Request) {
+	failure := s.failure(r.URL.Path)
+
+	s.mu.Lock()
+	s.requests[r.URL.Path]++
+	body, replaced := s.bodies[r.URL.Path]
+	s.mu.Unlock()
+
+	if !s.authorized(r) {
+		if r.Header.Get("Authorization") != "" {
+			http.Error(w, `{"error":"Invalid token"}`, http.StatusUnauthorized)
+		} else {
+			http.Redirect(w, r, "/welcome.html", http.StatusFound)
+		}
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/dataservice/tenant/") && strings.HasSuffix(r.URL.Path, "/vsessionid") {
+		s.vsession(w, r)
+		return
+	}
+
+	tenant := ""
+
+	if v := r.Header.Get("VSessionId"); v != "" {
+		s.mu.Lock()
+		t, ok := s.vsessions[v]
+		s.mu.Unlock()
+
+		if !ok {
+			http.Error(w, `{"error":"Invalid VSessionId"}`, http.StatusForbidden)
+			return
+		}
+
+		tenant = t
+	}
+
+	if failure != 0 {
+		if failure == http.StatusTooManyRequests || failure == http.StatusServiceUnavailable {
+			w.Header().Set("Retry-After", "0")
+		}
+
+		http.Error(w, http.StatusText(failure), failure)
+		return
+	}
+
+	if !replaced {
+		name, ok := routes[r.URL.Path]
+
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		b, err := fixtures.ReadFile("fixtures/" + name)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		body = string(b)
+	}
+
+	var res map[string]interface{}
+
+	if err := json.Unmarshal([]byte(body), &res); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	records, _ := res["data"].([]interface{})
+
+	if deviceID := r.URL.Query().Get("deviceId"); deviceID != "" {
+		records = filter(records, deviceID)
+	}
+
+	if tenant != "" {
+		records = filter(records, TenantDevices[tenant])
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/dataservice/data/device/state/") {
+		var pageInfo map[string]interface{}
+		records, pageInfo = paginate(records, r)
+		res["pageInfo"] = pageInfo
+	}
+
+	res["data"] = records
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) session(r *http.Request) (string, bool) {
+	c, err := r.Cookie("JSESSIONID")
+
+	if err != nil {
+		return "", false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.sessions[c.Value]
+	return c.Value, ok
+}
+
+func (s *Server) sessionToken(id string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.sessions[id]
+}
+
+// filter returns the records of a single device, which is referenced by different attributes depending on the endpoint.
+// Records without reference to a device are kept.
+func filter(records []interface{}, deviceID string) []interface{} {
+	res := []interface{}{}
+
+	for _, r := range records {
+		m, ok := r.(map[string]interface{})
+
+		if !ok {
+			continue
+		}
+
+		found := false
+
+		for _, key := range []string{"vdevice-name", "deviceId", "system_ip"} {
+			if v, ok := m[key]; ok {
+				found = true
+
+				if v == deviceID {
+					res = append(res, r)
+					break
+				}
+			}
+		}
+
+		if !found {
+			res = append(res, r)
+		}
+	}
+
+	return res
+}
+
+// paginate returns the page of records requested with count and startId,
+// which is the index of the last record of the previous page.
+func paginate(records []interface{}, r *http.Request) ([]interface{}, map[string]interface{}) {
+	start := 0
+
+	if v := r.URL.Query().Get("startId"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil {
+			start = i + 1
+		}
+	}
+
+	if start > len(records) {
+		start = len(records)
+	}
+
+	end := len(records)
+
+	if count, err := strconv.Atoi(r.URL.Query().Get("count")); err == nil && count > 0 && start+count < end {
+		end = start + count
+	}
+
+	pageInfo := map[string]interface{}{
+		"count":       end - start,
+		"moreEntries": end < len(records),
+	}
+
+	if end > start {
+		pageInfo["startId"] = strconv.Itoa(start)
+		pageInfo["endId"] = strconv.Itoa(end - 1)
+	}
+
+	return records[start:end], pageInfo
+}
+
+func loginPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	_, _ = fmt.Fprint(w, "<html><body>vManage login</body></html>")
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}